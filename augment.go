@@ -0,0 +1,207 @@
+package rbtree
+
+import "unsafe"
+
+// Augmentation describes a value of type A that is maintained for every
+// subtree of a Tree, such as a sum, a min/max or a count of the items
+// below a node.
+//
+// Combine(item, left, right) returns the aggregate of a subtree whose
+// root holds item, given the aggregates of its left and right subtrees.
+// Identity is the aggregate of an empty subtree. Combine must be
+// associative in the sense that the result depends only on the items
+// in sort order, not on the shape of the tree; it need not be
+// commutative.
+type Augmentation[T, A any] struct {
+	Identity A
+	Combine  func(item T, left, right A) A
+}
+
+// augNode is the node of a tree augmented with aggregates of type A.
+// The aggregate follows the node in memory, so trees without an
+// augmentation do not pay for it. The tree only handles it through its
+// embedded node, which must therefore come first.
+type augNode[T, A any] struct {
+	node[T]
+	agg A // of the subtree rooted at the node
+}
+
+// Return the aggregate stored with n, which must be embedded in an
+// augNode[T, A].
+func aggOf[A, T any](n *node[T]) *A {
+	return &(*augNode[T, A])(unsafe.Pointer(n)).agg
+}
+
+// augmenter maintains the aggregates of an augmented tree. Its only
+// implementation is typedAugmenter; the interface keeps A out of the
+// type of Tree.
+type augmenter[T any] interface {
+	// Allocate a zeroed node with room for the aggregate.
+	newNode() *node[T]
+	// Return a function that allocates such nodes poolSlabSize at a
+	// time, see nodePool.
+	slabAllocator() func() *node[T]
+	// Report whether the nodes of a tree augmented by other are laid
+	// out like the nodes allocated by this augmenter.
+	sameType(other augmenter[T]) bool
+	// Copy the aggregate of n to c.
+	copyAgg(c, n *node[T])
+	// Zero the aggregate of n, a deleted node.
+	clearAgg(n *node[T])
+	// Recompute the aggregate of n from n's item and its children.
+	update(n *node[T])
+	// Return the aggregate of [lo, hi) in root, see Tree.Aggregate.
+	aggregate(root *Tree[T], lo, hi T) any
+}
+
+type typedAugmenter[T, A any] Augmentation[T, A]
+
+// Create a new empty tree that maintains aug over every subtree. The
+// aggregate is recomputed along the modified path on every Insert and
+// delete, and for the nodes moved by each rotation, so Aggregate runs
+// in O(log n).
+func NewAugmentedTree[T, A any](compare func(a, b T) int, aug Augmentation[T, A]) *Tree[T] {
+	root := NewTree(compare)
+	root.augment = (*typedAugmenter[T, A])(&aug)
+	return root
+}
+
+// NewAugmentedMap Create a new empty Map that maintains aug over every
+// subtree, see NewAugmentedTree
+func NewAugmentedMap[K, V, A any](compare func(a, b K) int, aug Augmentation[Pair[K, V], A]) Map[K, V] {
+	comparePair := func(a, b Pair[K, V]) int {
+		return compare(a.key, b.key)
+	}
+	return Map[K, V]{tree: NewAugmentedTree(comparePair, aug)}
+}
+
+// Return the aggregate of all elements N such that lo <= N < hi, or
+// Identity if there are none.
+//
+// REQUIRES: the tree was created by NewAugmentedTree with an
+// Augmentation[T, A]
+func Aggregate[T, A any](root *Tree[T], lo, hi T) A {
+	aug, ok := root.augment.(*typedAugmenter[T, A])
+	if !ok {
+		panic("Aggregate called on a tree without an augmentation of that type")
+	}
+	return aug.rangeAggregate(root, lo, hi)
+}
+
+// AggregateMap return the aggregate of keys in [lo, hi), see Aggregate
+func AggregateMap[K, V, A any](m Map[K, V], lo, hi K) A {
+	return Aggregate[Pair[K, V], A](m.tree, Pair[K, V]{key: lo}, Pair[K, V]{key: hi})
+}
+
+// Return the aggregate of all elements N such that lo <= N < hi, see
+// Aggregate. The result has the dynamic type A of the Augmentation the
+// tree was created with; Aggregate returns it without the interface.
+//
+// REQUIRES: the tree was created by NewAugmentedTree
+func (root *Tree[T]) Aggregate(lo, hi T) any {
+	if root.augment == nil {
+		panic("Aggregate called on a tree without an augmentation")
+	}
+	return root.augment.aggregate(root, lo, hi)
+}
+
+// Aggregate return the aggregate of keys in [lo, hi), see Tree.Aggregate
+func (m Map[K, V]) Aggregate(lo, hi K) any {
+	return m.tree.Aggregate(Pair[K, V]{key: lo}, Pair[K, V]{key: hi})
+}
+
+func (aug *typedAugmenter[T, A]) newNode() *node[T] {
+	return &(&augNode[T, A]{}).node
+}
+
+func (aug *typedAugmenter[T, A]) slabAllocator() func() *node[T] {
+	return slabAllocator(func(n *augNode[T, A]) *node[T] { return &n.node })
+}
+
+func (aug *typedAugmenter[T, A]) sameType(other augmenter[T]) bool {
+	_, ok := other.(*typedAugmenter[T, A])
+	return ok
+}
+
+func (aug *typedAugmenter[T, A]) copyAgg(c, n *node[T]) {
+	*aggOf[A](c) = *aggOf[A](n)
+}
+
+func (aug *typedAugmenter[T, A]) clearAgg(n *node[T]) {
+	var zero A
+	*aggOf[A](n) = zero
+}
+
+func (aug *typedAugmenter[T, A]) update(n *node[T]) {
+	*aggOf[A](n) = aug.Combine(n.item, aug.agg(n.left), aug.agg(n.right))
+}
+
+func (aug *typedAugmenter[T, A]) aggregate(root *Tree[T], lo, hi T) any {
+	return aug.rangeAggregate(root, lo, hi)
+}
+
+// Return the aggregate of the subtree n.
+func (aug *typedAugmenter[T, A]) agg(n *node[T]) A {
+	if n == nil {
+		return aug.Identity
+	}
+	return *aggOf[A](n)
+}
+
+func (aug *typedAugmenter[T, A]) rangeAggregate(root *Tree[T], lo, hi T) A {
+	n := root.root
+	for n != nil {
+		if root.compare(n.item, lo) < 0 {
+			n = n.right
+		} else if root.compare(n.item, hi) >= 0 {
+			n = n.left
+		} else {
+			// n is the highest node inside [lo, hi): the range is the
+			// suffix of its left subtree, n, and the prefix of its
+			// right subtree.
+			return aug.Combine(n.item, aug.aggregateGE(root, n.left, lo), aug.aggregateLT(root, n.right, hi))
+		}
+	}
+	return aug.Identity
+}
+
+// Return the aggregate of the elements >= lo in the subtree n.
+func (aug *typedAugmenter[T, A]) aggregateGE(root *Tree[T], n *node[T], lo T) A {
+	if n == nil {
+		return aug.Identity
+	}
+	if root.compare(n.item, lo) < 0 {
+		return aug.aggregateGE(root, n.right, lo)
+	}
+	return aug.Combine(n.item, aug.aggregateGE(root, n.left, lo), aug.agg(n.right))
+}
+
+// Return the aggregate of the elements < hi in the subtree n.
+func (aug *typedAugmenter[T, A]) aggregateLT(root *Tree[T], n *node[T], hi T) A {
+	if n == nil {
+		return aug.Identity
+	}
+	if root.compare(n.item, hi) >= 0 {
+		return aug.aggregateLT(root, n.left, hi)
+	}
+	return aug.Combine(n.item, aug.agg(n.left), aug.aggregateLT(root, n.right, hi))
+}
+
+// Recompute the aggregate of n from n's item and its children. A no-op
+// if the tree is not augmented.
+func (root *Tree[T]) augmentNode(n *node[T]) {
+	if root.augment == nil {
+		return
+	}
+	root.augment.update(n)
+}
+
+// Recompute the aggregates of n and all of its ancestors.
+func (root *Tree[T]) augmentUp(n *node[T]) {
+	if root.augment == nil {
+		return
+	}
+	for ; n != nil; n = n.parent {
+		root.augment.update(n)
+	}
+}
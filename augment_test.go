@@ -0,0 +1,152 @@
+package rbtree
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testNewSumTree() *Tree[int] {
+	return NewAugmentedTree(func(a, b int) int { return a - b }, Augmentation[int, int]{
+		Identity: 0,
+		Combine:  func(item, left, right int) int { return left + item + right },
+	})
+}
+
+func TestAggregateSum(t *testing.T) {
+	tree := testNewSumTree()
+	present := map[int]bool{}
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 3000; i++ {
+		key := r.Intn(500)
+		if r.Intn(3) > 0 {
+			tree.Insert(key)
+			present[key] = true
+		} else {
+			tree.DeleteWithKey(key)
+			delete(present, key)
+		}
+		lo, hi := r.Intn(520)-10, r.Intn(520)-10
+		want := 0
+		for k := range present {
+			if k >= lo && k < hi {
+				want += k
+			}
+		}
+		assert.Equal(t, want, tree.Aggregate(lo, hi), "Aggregate(%d, %d)", lo, hi)
+	}
+}
+
+func TestAggregateKeepsOrder(t *testing.T) {
+	// String concatenation is not commutative, so this checks that
+	// rotations and range queries fold items in sort order.
+	tree := NewAugmentedTree(strings.Compare, Augmentation[string, string]{
+		Combine: func(item, left, right string) string { return left + item + right },
+	})
+	for _, s := range []string{"m", "c", "x", "a", "e", "q", "z", "b", "d", "f"} {
+		tree.Insert(s)
+	}
+	tree.DeleteWithKey("m")
+	assert.Equal(t, "abcdefqxz", tree.Aggregate("a", "zz"))
+	assert.Equal(t, "cdefq", tree.Aggregate("c", "r"))
+	assert.Equal(t, "", tree.Aggregate("r", "c"))
+	assert.Equal(t, "", tree.Aggregate("g", "p"))
+}
+
+func TestMapAggregate(t *testing.T) {
+	m := NewAugmentedMap[string, int](strings.Compare, Augmentation[Pair[string, int], int]{
+		Combine: func(p Pair[string, int], left, right int) int { return left + p.Value() + right },
+	})
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Set("c", 3)
+	assert.Equal(t, 5, m.Aggregate("b", "d"))
+	m.Set("b", 20)
+	assert.Equal(t, 23, m.Aggregate("b", "d"))
+	m.DeleteWithKey("c")
+	assert.Equal(t, 21, m.Aggregate("", "z"))
+}
+
+func TestAggregateWithoutAugmentationPanics(t *testing.T) {
+	assert.Panics(t, func() { testNewIntSet().Aggregate(0, 1) })
+}
+
+func TestTypedAggregate(t *testing.T) {
+	tree := testNewSumTree()
+	for i := 1; i <= 10; i++ {
+		tree.Insert(i)
+	}
+	assert.Equal(t, 55, Aggregate[int, int](tree, 0, 100))
+	assert.Equal(t, 2+3+4, Aggregate[int, int](tree, 2, 5))
+	assert.Equal(t, 0, Aggregate[int, int](tree, 5, 2))
+	assert.Panics(t, func() { Aggregate[int, string](tree, 0, 1) })
+	assert.Panics(t, func() { Aggregate[int, int](NewOrderedTree[int](), 0, 1) })
+
+	m := NewAugmentedMap[string, int](strings.Compare, Augmentation[Pair[string, int], int]{
+		Combine: func(p Pair[string, int], left, right int) int { return left + p.Value() + right },
+	})
+	m.Set("a", 1)
+	m.Set("b", 2)
+	assert.Equal(t, 2, AggregateMap[string, int, int](m, "b", "c"))
+}
+
+func TestAggregateDoesNotAllocate(t *testing.T) {
+	tree := testNewSumTree()
+	for i := 0; i < 1000; i += 2 {
+		tree.Insert(i)
+	}
+	// Only the node itself is allocated.
+	key := 0
+	allocs := testing.AllocsPerRun(100, func() {
+		key = (key + 2) % 1000
+		tree.Insert(key + 1)
+		tree.DeleteWithKey(key + 1)
+		Aggregate[int, int](tree, key, key+100)
+	})
+	assert.Equal(t, 1.0, allocs)
+	tree.PoolNodes(true)
+	tree.Insert(1)
+	tree.DeleteWithKey(1)
+	allocs = testing.AllocsPerRun(100, func() {
+		key = (key + 2) % 1000
+		tree.Insert(key + 1)
+		tree.DeleteWithKey(key + 1)
+	})
+	assert.Equal(t, 0.0, allocs)
+}
+
+func TestAggregateWithSnapshotAndPool(t *testing.T) {
+	tree := testNewSumTree()
+	tree.PoolNodes(true)
+	r := rand.New(rand.NewSource(1))
+	present := map[int]bool{}
+	for i := 0; i < 2000; i++ {
+		if i%100 == 0 {
+			// Copies every node later modified.
+			tree.Snapshot()
+		}
+		key := r.Intn(300)
+		if r.Intn(3) > 0 {
+			tree.Insert(key)
+			present[key] = true
+		} else {
+			tree.DeleteWithKey(key)
+			delete(present, key)
+		}
+	}
+	want := 0
+	for k := range present {
+		want += k
+	}
+	assert.Equal(t, want, Aggregate[int, int](tree, 0, 300))
+}
+
+func TestJoinDifferentAggregatesPanics(t *testing.T) {
+	a := testNewSumTree()
+	a.Insert(1)
+	b := NewOrderedTree[int]()
+	b.Insert(2)
+	assert.Panics(t, func() { Join(a, b) })
+}
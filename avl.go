@@ -0,0 +1,288 @@
+package rbtree
+
+import (
+	"cmp"
+	"iter"
+)
+
+// AVLTree is an AVL tree, an alternative backend of Ordered. Its
+// subtree heights differ by at most one, against a factor of two for a
+// red-black tree, so lookups visit fewer nodes while insertions and
+// deletions rotate more. It suits read-heavy workloads.
+type AVLTree[T any] struct {
+	root    *avlNode[T]
+	count   int
+	compare func(a, b T) int
+}
+
+type avlNode[T any] struct {
+	item        T
+	left, right *avlNode[T]
+	height      int // of the subtree, 1 for a leaf
+}
+
+// Create a new empty AVL tree. compare returns 0 if a==b, <0 if a<b,
+// >0 if a>b.
+func NewAVLTree[T any](compare func(a, b T) int) *AVLTree[T] {
+	return &AVLTree[T]{compare: compare}
+}
+
+// Create a new empty AVL tree of naturally ordered values, compared
+// with cmp.Compare.
+func NewOrderedAVLTree[T cmp.Ordered]() *AVLTree[T] {
+	return NewAVLTree(cmp.Compare[T])
+}
+
+// Return the number of elements in the tree.
+func (t *AVLTree[T]) Len() int {
+	return t.count
+}
+
+func avlHeight[T any](n *avlNode[T]) int {
+	if n == nil {
+		return 0
+	}
+	return n.height
+}
+
+func (n *avlNode[T]) update() {
+	n.height = max(avlHeight(n.left), avlHeight(n.right)) + 1
+}
+
+func (n *avlNode[T]) rotateLeft() *avlNode[T] {
+	r := n.right
+	n.right = r.left
+	r.left = n
+	n.update()
+	r.update()
+	return r
+}
+
+func (n *avlNode[T]) rotateRight() *avlNode[T] {
+	l := n.left
+	n.left = l.right
+	l.right = n
+	n.update()
+	l.update()
+	return l
+}
+
+// Restore the balance of n, whose subtrees are balanced and differ in
+// height by at most two, and return the new root of the subtree.
+func (n *avlNode[T]) rebalance() *avlNode[T] {
+	n.update()
+	switch balance := avlHeight(n.left) - avlHeight(n.right); {
+	case balance > 1:
+		if avlHeight(n.left.left) < avlHeight(n.left.right) {
+			n.left = n.left.rotateLeft()
+		}
+		return n.rotateRight()
+	case balance < -1:
+		if avlHeight(n.right.right) < avlHeight(n.right.left) {
+			n.right = n.right.rotateRight()
+		}
+		return n.rotateLeft()
+	}
+	return n
+}
+
+// Insert an item. If the item is already in the tree, do nothing and
+// return false. Else return true.
+func (t *AVLTree[T]) Insert(item T) bool {
+	var inserted bool
+	t.root, inserted = t.insert(t.root, item)
+	if inserted {
+		t.count++
+	}
+	return inserted
+}
+
+func (t *AVLTree[T]) insert(n *avlNode[T], item T) (*avlNode[T], bool) {
+	if n == nil {
+		return &avlNode[T]{item: item, height: 1}, true
+	}
+	var inserted bool
+	switch c := t.compare(item, n.item); {
+	case c < 0:
+		n.left, inserted = t.insert(n.left, item)
+	case c > 0:
+		n.right, inserted = t.insert(n.right, item)
+	default:
+		return n, false
+	}
+	if !inserted {
+		return n, false
+	}
+	return n.rebalance(), true
+}
+
+// Delete an item with the given key. Return true iff the item was
+// found.
+func (t *AVLTree[T]) DeleteWithKey(key T) bool {
+	var deleted bool
+	t.root, deleted = t.delete(t.root, key)
+	if deleted {
+		t.count--
+	}
+	return deleted
+}
+
+func (t *AVLTree[T]) delete(n *avlNode[T], key T) (*avlNode[T], bool) {
+	if n == nil {
+		return nil, false
+	}
+	var deleted bool
+	switch c := t.compare(key, n.item); {
+	case c < 0:
+		n.left, deleted = t.delete(n.left, key)
+	case c > 0:
+		n.right, deleted = t.delete(n.right, key)
+	default:
+		if n.left == nil {
+			return n.right, true
+		}
+		if n.right == nil {
+			return n.left, true
+		}
+		var min *avlNode[T]
+		n.right, min = deleteAVLMin(n.right)
+		min.left, min.right = n.left, n.right
+		return min.rebalance(), true
+	}
+	if !deleted {
+		return n, false
+	}
+	return n.rebalance(), true
+}
+
+// Unlink the minimum node of the subtree n. Return the new root of the
+// subtree and the unlinked node.
+func deleteAVLMin[T any](n *avlNode[T]) (rest, min *avlNode[T]) {
+	if n.left == nil {
+		return n.right, n
+	}
+	n.left, min = deleteAVLMin(n.left)
+	return n.rebalance(), min
+}
+
+// A convenience function for finding an element equal to key. Return
+// the zero value of T if not found.
+func (t *AVLTree[T]) Get(key T) T {
+	item, ok := t.GetGE(key)
+	if ok && t.compare(key, item) == 0 {
+		return item
+	}
+	var zero T
+	return zero
+}
+
+// Return the smallest element N such that N >= key, if any.
+func (t *AVLTree[T]) GetGE(key T) (item T, ok bool) {
+	for n := t.root; n != nil; {
+		c := t.compare(key, n.item)
+		if c == 0 {
+			return n.item, true
+		}
+		if c < 0 {
+			item, ok = n.item, true
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	return item, ok
+}
+
+// Return the largest element N such that N <= key, if any.
+func (t *AVLTree[T]) GetLE(key T) (item T, ok bool) {
+	for n := t.root; n != nil; {
+		c := t.compare(key, n.item)
+		if c == 0 {
+			return n.item, true
+		}
+		if c > 0 {
+			item, ok = n.item, true
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	return item, ok
+}
+
+// Return the minimum element, if any.
+func (t *AVLTree[T]) GetMin() (item T, ok bool) {
+	for n := t.root; n != nil; n = n.left {
+		item, ok = n.item, true
+	}
+	return item, ok
+}
+
+// Return the maximum element, if any.
+func (t *AVLTree[T]) GetMax() (item T, ok bool) {
+	for n := t.root; n != nil; n = n.right {
+		item, ok = n.item, true
+	}
+	return item, ok
+}
+
+// Return a sequence of the elements in ascending order. The tree must
+// not be modified while the sequence is being ranged over.
+func (t *AVLTree[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		t.ascend(t.root, nil, yield)
+	}
+}
+
+// Return a sequence of the elements in descending order.
+func (t *AVLTree[T]) Backward() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		t.descend(t.root, nil, yield)
+	}
+}
+
+// Return a sequence of the elements N >= key in ascending order.
+func (t *AVLTree[T]) AscendGE(key T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		t.ascend(t.root, &key, yield)
+	}
+}
+
+// Return a sequence of the elements N <= key in descending order.
+func (t *AVLTree[T]) DescendLE(key T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		t.descend(t.root, &key, yield)
+	}
+}
+
+// Call yield on the elements of n that are >= *from, or on all of them
+// if from is nil, in ascending order. Return false if yield did.
+func (t *AVLTree[T]) ascend(n *avlNode[T], from *T, yield func(T) bool) bool {
+	for n != nil {
+		if from != nil && t.compare(n.item, *from) < 0 {
+			n = n.right
+			continue
+		}
+		if !t.ascend(n.left, from, yield) || !yield(n.item) {
+			return false
+		}
+		from, n = nil, n.right
+	}
+	return true
+}
+
+// Call yield on the elements of n that are <= *from, or on all of them
+// if from is nil, in descending order. Return false if yield did.
+func (t *AVLTree[T]) descend(n *avlNode[T], from *T, yield func(T) bool) bool {
+	for n != nil {
+		if from != nil && t.compare(n.item, *from) > 0 {
+			n = n.left
+			continue
+		}
+		if !t.descend(n.right, from, yield) || !yield(n.item) {
+			return false
+		}
+		from, n = nil, n.left
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package rbtree
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Check the heights and balance of the subtree n, and return its
+// elements in order.
+func validateAVL(t *testing.T, n *avlNode[int]) []int {
+	if n == nil {
+		return nil
+	}
+	left, right := validateAVL(t, n.left), validateAVL(t, n.right)
+	assert.Equal(t, max(avlHeight(n.left), avlHeight(n.right))+1, n.height)
+	assert.LessOrEqual(t, avlHeight(n.left)-avlHeight(n.right), 1)
+	assert.GreaterOrEqual(t, avlHeight(n.left)-avlHeight(n.right), -1)
+	return append(append(left, n.item), right...)
+}
+
+func TestAVLTreeRandomized(t *testing.T) {
+	tree := NewOrderedAVLTree[int]()
+	r := rand.New(rand.NewSource(0))
+	o := map[int]bool{}
+	for round := 0; round < 10; round++ {
+		for i := 0; i < 2000; i++ {
+			key := r.Intn(3000)
+			if r.Intn(2) == 0 {
+				assert.Equal(t, o[key], tree.DeleteWithKey(key))
+				delete(o, key)
+			} else {
+				assert.Equal(t, !o[key], tree.Insert(key))
+				o[key] = true
+			}
+		}
+		items := validateAVL(t, tree.root)
+		assert.True(t, slices.IsSorted(items))
+		assert.Equal(t, len(o), len(items))
+		assert.Equal(t, tree.Len(), len(items))
+		for _, item := range items {
+			assert.True(t, o[item])
+			assert.Equal(t, item, tree.Get(item))
+		}
+	}
+}
+
+func TestAVLTreeSequential(t *testing.T) {
+	tree := NewOrderedAVLTree[int]()
+	for i := 0; i < 1<<10; i++ {
+		tree.Insert(i)
+	}
+	// A perfectly balanced tree of 2^10-1 nodes has height 10.
+	assert.Equal(t, 11, tree.root.height)
+	for i := 0; i < 1<<10; i += 2 {
+		tree.DeleteWithKey(i)
+	}
+	validateAVL(t, tree.root)
+	assert.Equal(t, 1<<9, tree.Len())
+}
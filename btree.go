@@ -0,0 +1,412 @@
+package rbtree
+
+import (
+	"cmp"
+	"iter"
+	"sort"
+)
+
+// BTree is an in-memory B-tree, an alternative backend of Ordered. Each
+// node holds up to 2*BTreeDegree-1 elements in a slice, so a lookup
+// touches few cache lines and the tree needs about one pointer per
+// BTreeDegree elements. It suits read-heavy workloads with small
+// elements. Insertions and deletions move elements within a node, and
+// so get slower as elements get larger.
+type BTree[T any] struct {
+	root    *bnode[T]
+	count   int
+	compare func(a, b T) int
+}
+
+// BTreeDegree is the minimum degree of a BTree: every node but the root
+// has between BTreeDegree-1 and 2*BTreeDegree-1 elements.
+const BTreeDegree = 32
+
+const (
+	btreeMaxItems = 2*BTreeDegree - 1
+	btreeMinItems = BTreeDegree - 1
+)
+
+// A node holds its elements in order, and, unless it is a leaf, one
+// more child than elements: children[i] holds the elements between
+// items[i-1] and items[i].
+type bnode[T any] struct {
+	items    []T
+	children []*bnode[T]
+}
+
+func (n *bnode[T]) leaf() bool {
+	return len(n.children) == 0
+}
+
+// Create a new empty B-tree. compare returns 0 if a==b, <0 if a<b, >0
+// if a>b.
+func NewBTree[T any](compare func(a, b T) int) *BTree[T] {
+	return &BTree[T]{compare: compare}
+}
+
+// Create a new empty B-tree of naturally ordered values, compared with
+// cmp.Compare.
+func NewOrderedBTree[T cmp.Ordered]() *BTree[T] {
+	return NewBTree(cmp.Compare[T])
+}
+
+// Return the number of elements in the tree.
+func (t *BTree[T]) Len() int {
+	return t.count
+}
+
+// Return the index of the first element of n that is >= key, and
+// whether it is equal to key.
+func (t *BTree[T]) find(n *bnode[T], key T) (int, bool) {
+	i := sort.Search(len(n.items), func(i int) bool {
+		return t.compare(n.items[i], key) >= 0
+	})
+	return i, i < len(n.items) && t.compare(n.items[i], key) == 0
+}
+
+// Insert an item. If the item is already in the tree, do nothing and
+// return false. Else return true.
+func (t *BTree[T]) Insert(item T) bool {
+	if t.root == nil {
+		t.root = &bnode[T]{items: make([]T, 0, btreeMaxItems)}
+	}
+	if len(t.root.items) == btreeMaxItems {
+		// Split the root on the way down, like any other full node.
+		mid, right := t.root.split()
+		t.root = &bnode[T]{
+			items:    append(make([]T, 0, btreeMaxItems), mid),
+			children: append(make([]*bnode[T], 0, btreeMaxItems+1), t.root, right),
+		}
+	}
+	if !t.insert(t.root, item) {
+		return false
+	}
+	t.count++
+	return true
+}
+
+// Insert item under n, which is not full.
+func (t *BTree[T]) insert(n *bnode[T], item T) bool {
+	for {
+		i, found := t.find(n, item)
+		if found {
+			return false
+		}
+		if n.leaf() {
+			n.items = insertAt(n.items, i, item)
+			return true
+		}
+		if len(n.children[i].items) == btreeMaxItems {
+			mid, right := n.children[i].split()
+			n.items = insertAt(n.items, i, mid)
+			n.children = insertAt(n.children, i+1, right)
+			switch c := t.compare(item, mid); {
+			case c == 0:
+				return false
+			case c > 0:
+				i++
+			}
+		}
+		n = n.children[i]
+	}
+}
+
+// Move the upper half of the full node n to a new node. Return the
+// middle element, which the caller must insert in the parent, and the
+// new node.
+func (n *bnode[T]) split() (T, *bnode[T]) {
+	const i = btreeMaxItems / 2
+	mid := n.items[i]
+	right := &bnode[T]{items: append(make([]T, 0, btreeMaxItems), n.items[i+1:]...)}
+	clear(n.items[i:])
+	n.items = n.items[:i]
+	if !n.leaf() {
+		right.children = append(make([]*bnode[T], 0, btreeMaxItems+1), n.children[i+1:]...)
+		clear(n.children[i+1:])
+		n.children = n.children[:i+1]
+	}
+	return mid, right
+}
+
+func insertAt[E any](s []E, i int, e E) []E {
+	var zero E
+	s = append(s, zero)
+	copy(s[i+1:], s[i:])
+	s[i] = e
+	return s
+}
+
+func removeAt[E any](s []E, i int) ([]E, E) {
+	e := s[i]
+	copy(s[i:], s[i+1:])
+	var zero E
+	s[len(s)-1] = zero
+	return s[:len(s)-1], e
+}
+
+// Delete an item with the given key. Return true iff the item was
+// found.
+func (t *BTree[T]) DeleteWithKey(key T) bool {
+	if t.root == nil {
+		return false
+	}
+	_, deleted := t.delete(t.root, &key)
+	if len(t.root.items) == 0 && !t.root.leaf() {
+		t.root = t.root.children[0]
+	}
+	if deleted {
+		t.count--
+	}
+	return deleted
+}
+
+// Delete the element equal to *key under n, or the maximum element if
+// key is nil, and return it. Every node visited below n is first given
+// more than the minimum number of elements, so that removing one from a
+// leaf never leaves it short.
+func (t *BTree[T]) delete(n *bnode[T], key *T) (T, bool) {
+	for {
+		var i int
+		var found bool
+		if key == nil {
+			i = len(n.items)
+			if n.leaf() {
+				i--
+				found = true
+			}
+		} else {
+			i, found = t.find(n, *key)
+		}
+		if n.leaf() {
+			if !found {
+				var zero T
+				return zero, false
+			}
+			var item T
+			n.items, item = removeAt(n.items, i)
+			return item, true
+		}
+		if len(n.children[i].items) <= btreeMinItems {
+			// Growing the child may move the key; search n again.
+			t.growChild(n, i)
+			continue
+		}
+		if found {
+			// Replace the element by its predecessor, the maximum of the
+			// subtree on its left.
+			item := n.items[i]
+			n.items[i], _ = t.delete(n.children[i], nil)
+			return item, true
+		}
+		n = n.children[i]
+	}
+}
+
+// Give the child i of n more than the minimum number of elements, by
+// moving one from a sibling through n, or else by merging it with a
+// sibling and the element of n between them.
+func (t *BTree[T]) growChild(n *bnode[T], i int) {
+	child := n.children[i]
+	switch {
+	case i > 0 && len(n.children[i-1].items) > btreeMinItems:
+		left := n.children[i-1]
+		var stolen T
+		left.items, stolen = removeAt(left.items, len(left.items)-1)
+		child.items = insertAt(child.items, 0, n.items[i-1])
+		n.items[i-1] = stolen
+		if !left.leaf() {
+			var c *bnode[T]
+			left.children, c = removeAt(left.children, len(left.children)-1)
+			child.children = insertAt(child.children, 0, c)
+		}
+	case i < len(n.items) && len(n.children[i+1].items) > btreeMinItems:
+		right := n.children[i+1]
+		var stolen T
+		right.items, stolen = removeAt(right.items, 0)
+		child.items = append(child.items, n.items[i])
+		n.items[i] = stolen
+		if !right.leaf() {
+			var c *bnode[T]
+			right.children, c = removeAt(right.children, 0)
+			child.children = append(child.children, c)
+		}
+	default:
+		if i == len(n.items) {
+			i--
+			child = n.children[i]
+		}
+		var mid T
+		var right *bnode[T]
+		n.items, mid = removeAt(n.items, i)
+		n.children, right = removeAt(n.children, i+1)
+		child.items = append(append(child.items, mid), right.items...)
+		child.children = append(child.children, right.children...)
+	}
+}
+
+// A convenience function for finding an element equal to key. Return
+// the zero value of T if not found.
+func (t *BTree[T]) Get(key T) T {
+	for n := t.root; n != nil; {
+		i, found := t.find(n, key)
+		if found {
+			return n.items[i]
+		}
+		if n.leaf() {
+			break
+		}
+		n = n.children[i]
+	}
+	var zero T
+	return zero
+}
+
+// Return the smallest element N such that N >= key, if any.
+func (t *BTree[T]) GetGE(key T) (item T, ok bool) {
+	for n := t.root; n != nil; {
+		i, found := t.find(n, key)
+		if i < len(n.items) {
+			item, ok = n.items[i], true
+			if found {
+				break
+			}
+		}
+		if n.leaf() {
+			break
+		}
+		n = n.children[i]
+	}
+	return item, ok
+}
+
+// Return the largest element N such that N <= key, if any.
+func (t *BTree[T]) GetLE(key T) (item T, ok bool) {
+	for n := t.root; n != nil; {
+		i, found := t.find(n, key)
+		if found {
+			return n.items[i], true
+		}
+		if i > 0 {
+			item, ok = n.items[i-1], true
+		}
+		if n.leaf() {
+			break
+		}
+		n = n.children[i]
+	}
+	return item, ok
+}
+
+// Return the minimum element, if any.
+func (t *BTree[T]) GetMin() (item T, ok bool) {
+	n := t.root
+	if n == nil || len(n.items) == 0 {
+		return item, false
+	}
+	for !n.leaf() {
+		n = n.children[0]
+	}
+	return n.items[0], true
+}
+
+// Return the maximum element, if any.
+func (t *BTree[T]) GetMax() (item T, ok bool) {
+	n := t.root
+	if n == nil || len(n.items) == 0 {
+		return item, false
+	}
+	for !n.leaf() {
+		n = n.children[len(n.children)-1]
+	}
+	return n.items[len(n.items)-1], true
+}
+
+// Return a sequence of the elements in ascending order. The tree must
+// not be modified while the sequence is being ranged over.
+func (t *BTree[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		if t.root != nil {
+			t.ascend(t.root, nil, yield)
+		}
+	}
+}
+
+// Return a sequence of the elements in descending order.
+func (t *BTree[T]) Backward() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		if t.root != nil {
+			t.descend(t.root, nil, yield)
+		}
+	}
+}
+
+// Return a sequence of the elements N >= key in ascending order.
+func (t *BTree[T]) AscendGE(key T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		if t.root != nil {
+			t.ascend(t.root, &key, yield)
+		}
+	}
+}
+
+// Return a sequence of the elements N <= key in descending order.
+func (t *BTree[T]) DescendLE(key T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		if t.root != nil {
+			t.descend(t.root, &key, yield)
+		}
+	}
+}
+
+// Call yield on the elements under n that are >= *from, or on all of
+// them if from is nil, in ascending order. Return false if yield did.
+func (t *BTree[T]) ascend(n *bnode[T], from *T, yield func(T) bool) bool {
+	i := 0
+	if from != nil {
+		var found bool
+		i, found = t.find(n, *from)
+		// Unless items[i] is *from, children[i] may hold elements
+		// between *from and items[i].
+		if !found && !n.leaf() && !t.ascend(n.children[i], from, yield) {
+			return false
+		}
+	} else if !n.leaf() && !t.ascend(n.children[0], nil, yield) {
+		return false
+	}
+	for ; i < len(n.items); i++ {
+		if !yield(n.items[i]) {
+			return false
+		}
+		if !n.leaf() && !t.ascend(n.children[i+1], nil, yield) {
+			return false
+		}
+	}
+	return true
+}
+
+// Call yield on the elements under n that are <= *from, or on all of
+// them if from is nil, in descending order. Return false if yield did.
+func (t *BTree[T]) descend(n *bnode[T], from *T, yield func(T) bool) bool {
+	i := len(n.items)
+	if from != nil {
+		var found bool
+		i, found = t.find(n, *from)
+		if found {
+			i++
+		} else if !n.leaf() && !t.descend(n.children[i], from, yield) {
+			return false
+		}
+	} else if !n.leaf() && !t.descend(n.children[i], nil, yield) {
+		return false
+	}
+	for i--; i >= 0; i-- {
+		if !yield(n.items[i]) {
+			return false
+		}
+		if !n.leaf() && !t.descend(n.children[i], nil, yield) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package rbtree
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Check the node sizes and leaf depths under n, the node at the given
+// depth, and return its elements in order and the depth of its leaves.
+func validateBTree(t *testing.T, n *bnode[int], depth int, isRoot bool) ([]int, int) {
+	assert.LessOrEqual(t, len(n.items), btreeMaxItems)
+	if !isRoot {
+		assert.GreaterOrEqual(t, len(n.items), btreeMinItems)
+	}
+	if n.leaf() {
+		return slices.Clone(n.items), depth
+	}
+	assert.Equal(t, len(n.items)+1, len(n.children))
+	var items []int
+	leafDepth := -1
+	for i, c := range n.children {
+		sub, d := validateBTree(t, c, depth+1, false)
+		if leafDepth >= 0 {
+			assert.Equal(t, leafDepth, d)
+		}
+		leafDepth = d
+		items = append(items, sub...)
+		if i < len(n.items) {
+			items = append(items, n.items[i])
+		}
+	}
+	return items, leafDepth
+}
+
+func TestBTreeRandomized(t *testing.T) {
+	tree := NewOrderedBTree[int]()
+	r := rand.New(rand.NewSource(0))
+	o := map[int]bool{}
+	for round := 0; round < 20; round++ {
+		for i := 0; i < 5000; i++ {
+			key := r.Intn(20000)
+			// Grow the tree over the first rounds, then shrink it.
+			if r.Intn(20) < round {
+				assert.Equal(t, o[key], tree.DeleteWithKey(key))
+				delete(o, key)
+			} else {
+				assert.Equal(t, !o[key], tree.Insert(key))
+				o[key] = true
+			}
+		}
+		items, depth := validateBTree(t, tree.root, 0, true)
+		if round == 10 {
+			assert.GreaterOrEqual(t, depth, 2)
+		}
+		assert.True(t, slices.IsSorted(items))
+		assert.Equal(t, len(o), len(items))
+		assert.Equal(t, tree.Len(), len(items))
+		for _, item := range items {
+			assert.True(t, o[item])
+			assert.Equal(t, item, tree.Get(item))
+		}
+	}
+}
+
+func TestBTreeDeleteAll(t *testing.T) {
+	tree := NewOrderedBTree[int]()
+	assert.False(t, tree.DeleteWithKey(1))
+	for i := 0; i < 10000; i++ {
+		tree.Insert(i)
+	}
+	for i := 0; i < 10000; i++ {
+		assert.True(t, tree.DeleteWithKey(i))
+		if i%1000 == 0 {
+			validateBTree(t, tree.root, 0, true)
+		}
+	}
+	assert.Equal(t, 0, tree.Len())
+	_, ok := tree.GetMin()
+	assert.False(t, ok)
+	assert.True(t, tree.Insert(1))
+	assert.Equal(t, []int{1}, slices.Collect(tree.All()))
+}
@@ -0,0 +1,100 @@
+package rbtree
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// ErrUnsorted is returned by the bulk constructors when their input is
+// not in ascending order.
+var ErrUnsorted = errors.New("rbtree: input is not sorted")
+
+// Create a tree holding items in O(n) time, without rebalancing. items
+// must be sorted in ascending order by compare; runs of equal items are
+// collapsed to their first element, as repeated Insert calls would do.
+// If an item is smaller than its predecessor, return an error wrapping
+// ErrUnsorted.
+//
+// items is not retained by the tree.
+func NewTreeFromSorted[T any](items []T, compare func(a, b T) int) (*Tree[T], error) {
+	root := NewTree(compare)
+	if err := root.loadSorted(items); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// NewMapFromSorted Create a Map from keys[i] -> values[i] in O(n) time,
+// see NewTreeFromSorted. keys must be sorted in ascending order
+func NewMapFromSorted[K, V any](keys []K, values []V, compare func(a, b K) int) (Map[K, V], error) {
+	if len(keys) != len(values) {
+		return Map[K, V]{}, fmt.Errorf("rbtree: %d keys but %d values", len(keys), len(values))
+	}
+	m := NewMap[K, V](compare)
+	pairs := make([]Pair[K, V], len(keys))
+	for i := range keys {
+		pairs[i] = Pair[K, V]{keys[i], values[i]}
+	}
+	if err := m.tree.loadSorted(pairs); err != nil {
+		return Map[K, V]{}, err
+	}
+	return m, nil
+}
+
+// Replace the contents of an empty tree by items, see NewTreeFromSorted.
+func (root *Tree[T]) loadSorted(items []T) error {
+	doAssert(root.root == nil)
+	unique := 0
+	for i := range items {
+		if i > 0 {
+			c := root.compare(items[i-1], items[i])
+			if c > 0 {
+				return fmt.Errorf("item %d is smaller than item %d: %w", i, i-1, ErrUnsorted)
+			}
+			if c == 0 {
+				continue
+			}
+		}
+		unique++
+	}
+	if unique != len(items) {
+		deduped := make([]T, 0, unique)
+		for i := range items {
+			if i == 0 || root.compare(items[i-1], items[i]) != 0 {
+				deduped = append(deduped, items[i])
+			}
+		}
+		items = deduped
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	// A perfectly balanced tree has all its nil links on its two
+	// deepest levels. Painting the nodes on the deepest level red gives
+	// every path the same number of black nodes.
+	redDepth := bits.Len(uint(len(items))) - 1
+	root.root = root.buildSorted(items, nil, 0, redDepth)
+	root.root.color = black
+	root.count = len(items)
+	root.recomputeMinNode()
+	root.recomputeMaxNode()
+	return nil
+}
+
+func (root *Tree[T]) buildSorted(items []T, parent *node[T], depth, redDepth int) *node[T] {
+	if len(items) == 0 {
+		return nil
+	}
+	mid := len(items) / 2
+	n := root.newNode(items[mid], parent)
+	n.size, n.color = len(items), black
+	if depth == redDepth {
+		n.color = red
+	}
+	n.left = root.buildSorted(items[:mid], n, depth+1, redDepth)
+	n.right = root.buildSorted(items[mid+1:], n, depth+1, redDepth)
+	root.augmentNode(n)
+	return n
+}
@@ -0,0 +1,72 @@
+package rbtree
+
+import (
+	"cmp"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTreeFromSorted(t *testing.T) {
+	for n := 0; n < 300; n++ {
+		items := make([]int, n)
+		for i := range items {
+			items[i] = i * 2
+		}
+		tree, err := NewTreeFromSorted(items, cmp.Compare[int])
+		assert.NoError(t, err)
+		validateTree2(tree)
+		assert.Equal(t, n, tree.Len())
+		assert.Equal(t, items, treeToSlice(tree))
+		if n > 0 {
+			assert.True(t, tree.Min().Min())
+			assert.Equal(t, 0, tree.Min().Item())
+			assert.Equal(t, 2*(n-1), tree.Max().Item())
+			assert.Equal(t, n/2*2, tree.Select(n/2).Item())
+		}
+
+		// The result is an ordinary tree that keeps working under
+		// updates.
+		tree.Insert(-1)
+		tree.Insert(2*n + 1)
+		tree.DeleteWithKey(n / 2 * 2)
+		validateTree2(tree)
+	}
+}
+
+func TestNewTreeFromSortedDeduplicates(t *testing.T) {
+	tree, err := NewTreeFromSorted([]int{1, 1, 2, 3, 3, 3, 4}, cmp.Compare[int])
+	assert.NoError(t, err)
+	validateTree2(tree)
+	assert.Equal(t, []int{1, 2, 3, 4}, treeToSlice(tree))
+}
+
+func TestNewTreeFromSortedRejectsUnsorted(t *testing.T) {
+	tree, err := NewTreeFromSorted([]int{1, 3, 2}, cmp.Compare[int])
+	assert.Nil(t, tree)
+	assert.True(t, errors.Is(err, ErrUnsorted))
+	assert.Contains(t, err.Error(), "item 2")
+}
+
+func TestNewTreeFromSortedAugmented(t *testing.T) {
+	tree := testNewSumTree()
+	assert.NoError(t, tree.loadSorted([]int{1, 2, 3, 4, 5}))
+	validateTree2(tree)
+	assert.Equal(t, 9, tree.Aggregate(2, 5))
+}
+
+func TestNewMapFromSorted(t *testing.T) {
+	m, err := NewMapFromSorted([]string{"a", "b", "c"}, []int{1, 2, 3}, cmp.Compare[string])
+	assert.NoError(t, err)
+	validateTree2(m.Tree())
+	v, ok := m.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+	assert.Equal(t, 3, m.Len())
+
+	_, err = NewMapFromSorted([]string{"b", "a"}, []int{1, 2}, cmp.Compare[string])
+	assert.True(t, errors.Is(err, ErrUnsorted))
+	_, err = NewMapFromSorted([]string{"a"}, []int{1, 2}, cmp.Compare[string])
+	assert.Error(t, err)
+}
@@ -0,0 +1,923 @@
+package rbtree
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"slices"
+)
+
+// CompactTree is a red-black tree whose nodes live in chunks of 1024
+// and link to each other by uint32 indices, with the color packed into
+// the top bit of the parent index. A node costs 20 bytes besides its
+// item, against 48 for Tree, and the garbage collector sees a few large
+// chunks instead of a graph of pointers; if T holds no pointers, it
+// does not scan the nodes at all.
+//
+// CompactTree has the methods of Tree, except for augmentation, and
+// CompactMap is built on it as Map is on Tree. Its rebalancing code is
+// shared with DiskTree. Two operations cost more than Tree's, since the
+// nodes of a tree live in its own chunks:
+//
+//   - After a Snapshot, the first modification of a node shared with
+//     the snapshot copies the node's whole chunk, not just the node.
+//   - Split and JoinCompact keep the nodes of the larger side in place
+//     and move the k elements of the smaller side one at a time, in
+//     O(k log n) time.
+//
+// Iterators follow the invalidation rules of Iterator, and using an
+// invalid one panics in the same way, see CheckIterators. A CompactTree
+// holds at most 2^31-2 elements.
+type CompactTree[T any] struct {
+	// Node n is compactChunks[n>>compactChunkBits].nodes[n%compactChunkSize].
+	// Node 0 is the nil sentinel, which is always black.
+	compactChunks[T]
+	// Set when a snapshot shares compactChunks itself, which must then
+	// be copied before a chunk is replaced or added.
+	chunksShared bool
+	// Bumped by Snapshot. Chunks of an older generation are shared with
+	// a snapshot, see mut.
+	gen              uint64
+	root             uint32
+	minNode, maxNode uint32
+	free             uint32 // deleted nodes, linked through left
+	count            int
+	compare          func(a, b T) int
+
+	// Bumped by the operations that invalidate every iterator, such as
+	// Split. Iterators record it when created.
+	epoch uint64
+
+	// Disables the invalidation checks. See CheckIterators.
+	uncheckedIterators bool
+}
+
+type compactNode[T any] struct {
+	item        T
+	parent      uint32 // the top bit is the color
+	left, right uint32
+	size        uint32 // number of nodes in this subtree, including n
+	reuse       uint32 // number of times the node was deleted, see Iterator
+}
+
+type compactChunk[T any] struct {
+	gen   uint64 // of the tree that may modify the chunk in place
+	nodes []compactNode[T]
+}
+
+// compactChunks holds the nodes of a CompactTree or CompactSnapshot,
+// and reads their links.
+type compactChunks[T any] []*compactChunk[T]
+
+const (
+	// The index of nil links and of the Limit iterator.
+	compactNil = indexNil
+	// The index of the NegativeLimit iterator.
+	compactNegativeLimit = indexNegativeLimit
+	compactColorBit      = uint32(1) << 31
+
+	compactChunkBits = 10
+	compactChunkSize = 1 << compactChunkBits
+)
+
+// Create a new empty compact tree. compare returns 0 if a==b, <0 if
+// a<b, >0 if a>b.
+func NewCompactTree[T any](compare func(a, b T) int) *CompactTree[T] {
+	t := &CompactTree[T]{compare: compare}
+	t.Reset()
+	return t
+}
+
+// Create a new empty compact tree of naturally ordered values, compared
+// with cmp.Compare.
+func NewOrderedCompactTree[T cmp.Ordered]() *CompactTree[T] {
+	return NewCompactTree(cmp.Compare[T])
+}
+
+// Delete every element of the tree and release its memory at once.
+// Every iterator on the tree becomes invalid; snapshots are not
+// affected.
+func (t *CompactTree[T]) Reset() {
+	t.compactChunks = compactChunks[T]{{gen: t.gen, nodes: []compactNode[T]{{parent: compactColorBit}}}}
+	t.chunksShared = false
+	t.root, t.minNode, t.maxNode, t.free, t.count = compactNil, compactNil, compactNil, compactNil, 0
+	t.epoch++
+}
+
+// Enable or disable the detection of invalidated iterators, which is on
+// by default, see Tree.CheckIterators.
+func (t *CompactTree[T]) CheckIterators(enabled bool) {
+	t.uncheckedIterators = !enabled
+}
+
+func (c compactChunks[T]) at(n uint32) *compactNode[T] {
+	return &c[n>>compactChunkBits].nodes[n%compactChunkSize]
+}
+
+func (c compactChunks[T]) parent(n uint32) uint32 { return c.at(n).parent &^ compactColorBit }
+func (c compactChunks[T]) left(n uint32) uint32   { return c.at(n).left }
+func (c compactChunks[T]) right(n uint32) uint32  { return c.at(n).right }
+func (c compactChunks[T]) size(n uint32) uint32   { return c.at(n).size }
+
+func (c compactChunks[T]) color(n uint32) int {
+	return int(c.at(n).parent >> 31)
+}
+
+// Find a node whose item >= key in the tree rooted at root, see
+// Tree.findGE.
+func (c compactChunks[T]) findGE(root uint32, compare func(a, b T) int, key T) (uint32, bool) {
+	n, ge := root, compactNil
+	for n != compactNil {
+		x := compare(key, c.at(n).item)
+		if x == 0 {
+			return n, true
+		}
+		if x < 0 {
+			ge, n = n, c.left(n)
+		} else {
+			n = c.right(n)
+		}
+	}
+	return ge, false
+}
+
+// Find the node of the largest item <= key in the tree rooted at root,
+// or compactNegativeLimit.
+func (c compactChunks[T]) findLE(root uint32, compare func(a, b T) int, key T) uint32 {
+	n, le := root, compactNegativeLimit
+	for n != compactNil {
+		x := compare(key, c.at(n).item)
+		if x == 0 {
+			return n
+		}
+		if x > 0 {
+			le, n = n, c.right(n)
+		} else {
+			n = c.left(n)
+		}
+	}
+	return le
+}
+
+// Return node n for writing. If its chunk is shared with a snapshot,
+// the chunk is first replaced by a private copy.
+func (t *CompactTree[T]) mut(n uint32) *compactNode[T] {
+	if i := n >> compactChunkBits; t.compactChunks[i].gen != t.gen {
+		t.ownChunk(i)
+	}
+	return t.at(n)
+}
+
+func (t *CompactTree[T]) ownChunk(i uint32) {
+	t.ownChunks()
+	t.compactChunks[i] = &compactChunk[T]{gen: t.gen, nodes: slices.Clone(t.compactChunks[i].nodes)}
+}
+
+// Make sure that compactChunks is not shared with a snapshot.
+func (t *CompactTree[T]) ownChunks() {
+	if t.chunksShared {
+		t.compactChunks = slices.Clone(t.compactChunks)
+		t.chunksShared = false
+	}
+}
+
+// The setters leave the sentinel alone, see indexLinks.
+
+func (t *CompactTree[T]) setParent(n, p uint32) {
+	if n != compactNil {
+		x := t.mut(n)
+		x.parent = x.parent&compactColorBit | p
+	}
+}
+
+func (t *CompactTree[T]) setLeft(n, l uint32) {
+	if n != compactNil {
+		t.mut(n).left = l
+	}
+}
+
+func (t *CompactTree[T]) setRight(n, r uint32) {
+	if n != compactNil {
+		t.mut(n).right = r
+	}
+}
+
+func (t *CompactTree[T]) setColor(n uint32, color int) {
+	if n != compactNil {
+		x := t.mut(n)
+		x.parent = x.parent&^compactColorBit | uint32(color)<<31
+	}
+}
+
+// Recompute the sizes of n and c after a rotation moved n below c.
+func (t *CompactTree[T]) rotated(n, c uint32) {
+	t.mut(c).size = t.size(n)
+	t.mut(n).size = t.size(t.left(n)) + t.size(t.right(n)) + 1
+}
+
+// Return a new red leaf holding item under parent.
+func (t *CompactTree[T]) allocNode(item T, parent uint32) uint32 {
+	n := t.free
+	if n != compactNil {
+		t.free = t.left(n)
+	} else {
+		n = t.newSlot()
+	}
+	x := t.mut(n)
+	*x = compactNode[T]{item: item, parent: parent, size: 1, reuse: x.reuse}
+	return n
+}
+
+// Add a node slot at the end of the last chunk, or in a new chunk.
+func (t *CompactTree[T]) newSlot() uint32 {
+	last := uint32(len(t.compactChunks) - 1)
+	c := t.compactChunks[last]
+	n := last<<compactChunkBits + uint32(len(c.nodes))
+	if n > compactColorBit-2 {
+		panic("rbtree: CompactTree is full")
+	}
+	if len(c.nodes) == compactChunkSize {
+		t.ownChunks()
+		t.compactChunks = append(t.compactChunks, &compactChunk[T]{gen: t.gen})
+		last++
+	} else if c.gen != t.gen {
+		t.ownChunk(last)
+	}
+	c = t.compactChunks[last]
+	c.nodes = append(c.nodes, compactNode[T]{})
+	return n
+}
+
+func (t *CompactTree[T]) freeNode(n uint32) {
+	// Drop the item, which may hold pointers, and make the iterators to
+	// n invalid.
+	x := t.mut(n)
+	*x = compactNode[T]{left: t.free, reuse: x.reuse + 1}
+	t.free = n
+}
+
+// Return the number of elements in the tree.
+func (t *CompactTree[T]) Len() int {
+	return t.count
+}
+
+// A convenience function for finding an element equal to key. Return
+// the zero value of T if not found.
+func (t *CompactTree[T]) Get(key T) T {
+	n, exact := t.findGE(key)
+	if exact {
+		return t.at(n).item
+	}
+	var zero T
+	return zero
+}
+
+// Find a node whose item >= key, see Tree.findGE.
+func (t *CompactTree[T]) findGE(key T) (uint32, bool) {
+	return t.compactChunks.findGE(t.root, t.compare, key)
+}
+
+// Create an iterator pointing to n.
+func (t *CompactTree[T]) iterator(n uint32) CompactIterator[T] {
+	iter := CompactIterator[T]{tree: t, node: n, epoch: t.epoch}
+	if n != compactNil && n != compactNegativeLimit {
+		iter.reuse = t.at(n).reuse
+	}
+	return iter
+}
+
+// Find the smallest element N such that N >= key, and return the
+// iterator pointing to the element. If no such element is found,
+// return Limit().
+func (t *CompactTree[T]) FindGE(key T) CompactIterator[T] {
+	n, _ := t.findGE(key)
+	return t.iterator(n)
+}
+
+// Find the largest element N such that N <= key, and return the
+// iterator pointing to the element. If no such element is found,
+// return NegativeLimit().
+func (t *CompactTree[T]) FindLE(key T) CompactIterator[T] {
+	return t.iterator(t.findLE(t.root, t.compare, key))
+}
+
+// Create an iterator that points to the minimum item in the tree. If
+// the tree is empty, return Limit().
+func (t *CompactTree[T]) Min() CompactIterator[T] {
+	return t.iterator(t.minNode)
+}
+
+// Create an iterator that points at the maximum item in the tree. If
+// the tree is empty, return NegativeLimit().
+func (t *CompactTree[T]) Max() CompactIterator[T] {
+	if t.maxNode == compactNil {
+		return t.NegativeLimit()
+	}
+	return t.iterator(t.maxNode)
+}
+
+// Create an iterator that points beyond the maximum item in the tree.
+func (t *CompactTree[T]) Limit() CompactIterator[T] {
+	return t.iterator(compactNil)
+}
+
+// Create an iterator that points before the minimum item in the tree.
+func (t *CompactTree[T]) NegativeLimit() CompactIterator[T] {
+	return t.iterator(compactNegativeLimit)
+}
+
+// Return the number of elements N such that N < key.
+func (t *CompactTree[T]) Rank(key T) int {
+	rank := 0
+	for n := t.root; n != compactNil; {
+		if t.compare(key, t.at(n).item) <= 0 {
+			n = t.left(n)
+		} else {
+			rank += int(t.size(t.left(n))) + 1
+			n = t.right(n)
+		}
+	}
+	return rank
+}
+
+// Create an iterator that points to the i'th smallest element
+// (0-based). Return NegativeLimit() if i < 0 and Limit() if
+// i >= Len().
+func (t *CompactTree[T]) Select(i int) CompactIterator[T] {
+	if i < 0 {
+		return t.NegativeLimit()
+	}
+	n := t.root
+	for n != compactNil {
+		leftSize := int(t.size(t.left(n)))
+		if i < leftSize {
+			n = t.left(n)
+		} else if i == leftSize {
+			break
+		} else {
+			i -= leftSize + 1
+			n = t.right(n)
+		}
+	}
+	return t.iterator(n)
+}
+
+// Return the number of elements N such that lo <= N < hi.
+func (t *CompactTree[T]) CountRange(lo, hi T) int {
+	if t.compare(lo, hi) >= 0 {
+		return 0
+	}
+	return t.Rank(hi) - t.Rank(lo)
+}
+
+// Call fn on every element N between lo and hi, as selected by opts,
+// until fn returns false. fn must not modify the tree.
+func (t *CompactTree[T]) Range(lo, hi T, opts RangeOptions, fn func(item T) bool) {
+	for n := t.rangeStart(lo, hi, opts); n != compactNil && n != compactNegativeLimit; {
+		item := t.at(n).item
+		if !t.inRange(item, lo, hi, opts) || !fn(item) {
+			return
+		}
+		if opts.Descending {
+			n = indexPrev(t, n)
+		} else {
+			n = indexNext(t, n)
+		}
+	}
+}
+
+// Return the first node visited by Range, see Tree.rangeStart.
+func (t *CompactTree[T]) rangeStart(lo, hi T, opts RangeOptions) uint32 {
+	if !opts.Descending {
+		if opts.LoUnbounded {
+			return t.minNode
+		}
+		n, exact := t.findGE(lo)
+		if exact && opts.Bounds&ExcludeLo != 0 {
+			n = indexNext(t, n)
+		}
+		return n
+	}
+	if opts.HiUnbounded {
+		return t.maxNode
+	}
+	n, exact := t.findGE(hi)
+	if exact && opts.Bounds&IncludeHi != 0 {
+		return n
+	}
+	if n == compactNil {
+		return t.maxNode
+	}
+	return indexPrev(t, n)
+}
+
+// Report whether item has not passed the far end of the range walked
+// by Range.
+func (t *CompactTree[T]) inRange(item, lo, hi T, opts RangeOptions) bool {
+	if opts.Descending {
+		if opts.LoUnbounded {
+			return true
+		}
+		c := t.compare(item, lo)
+		return c > 0 || c == 0 && opts.Bounds&ExcludeLo == 0
+	}
+	if opts.HiUnbounded {
+		return true
+	}
+	c := t.compare(item, hi)
+	return c < 0 || c == 0 && opts.Bounds&IncludeHi != 0
+}
+
+// Return a sequence of the elements in ascending order. The tree must
+// not be modified while the sequence is being ranged over.
+func (t *CompactTree[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for n := t.minNode; n != compactNil && yield(t.at(n).item); n = indexNext(t, n) {
+		}
+	}
+}
+
+// Return a sequence of the elements in descending order. The tree must
+// not be modified while the sequence is being ranged over.
+func (t *CompactTree[T]) Backward() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for n := t.maxNode; n != compactNegativeLimit && n != compactNil && yield(t.at(n).item); n = indexPrev(t, n) {
+		}
+	}
+}
+
+// Return a sequence of the elements between lo and hi, as selected by
+// opts, see Range.
+func (t *CompactTree[T]) Between(lo, hi T, opts RangeOptions) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		t.Range(lo, hi, opts, yield)
+	}
+}
+
+// Find where item belongs: its node if it is in the tree, else the
+// parent of the new leaf and the side to link it on.
+func (t *CompactTree[T]) locate(item T) (found, parent uint32, comp int) {
+	parent = compactNil
+	for n := t.root; n != compactNil; {
+		parent = n
+		comp = t.compare(item, t.at(n).item)
+		if comp == 0 {
+			return n, parent, 0
+		}
+		if comp < 0 {
+			n = t.left(n)
+		} else {
+			n = t.right(n)
+		}
+	}
+	return compactNil, parent, comp
+}
+
+// Insert an item. If the item is already in the tree, do nothing and
+// return false. Else return true.
+func (t *CompactTree[T]) Insert(item T) bool {
+	found, p, c := t.locate(item)
+	if found != compactNil {
+		return false
+	}
+	t.link(item, p, c)
+	return true
+}
+
+// Insert item, or replace the element equal to it by item. Return the
+// replaced element and true, or the zero value and false if item was
+// inserted.
+func (t *CompactTree[T]) ReplaceOrInsert(item T) (old T, replaced bool) {
+	found, p, c := t.locate(item)
+	if found != compactNil {
+		x := t.mut(found)
+		old, x.item = x.item, item
+		return old, true
+	}
+	t.link(item, p, c)
+	return old, false
+}
+
+// Add item in a new leaf under p, on the side given by c, and
+// rebalance.
+func (t *CompactTree[T]) link(item T, p uint32, c int) {
+	n := t.allocNode(item, p)
+	switch {
+	case p == compactNil:
+		t.root, t.minNode, t.maxNode = n, n, n
+	case c < 0:
+		t.setLeft(p, n)
+		if p == t.minNode {
+			t.minNode = n
+		}
+	default:
+		t.setRight(p, n)
+		if p == t.maxNode {
+			t.maxNode = n
+		}
+	}
+	for ; p != compactNil; p = t.parent(p) {
+		t.mut(p).size++
+	}
+	indexInsertFixup(t, &t.root, n)
+	t.count++
+}
+
+// Delete an item with the given key. Return true iff the item was
+// found.
+func (t *CompactTree[T]) DeleteWithKey(key T) bool {
+	n, exact := t.findGE(key)
+	if !exact {
+		return false
+	}
+	t.deleteNode(n)
+	return true
+}
+
+// Delete the current item.
+//
+// REQUIRES: !iter.Limit() && !iter.NegativeLimit()
+func (t *CompactTree[T]) DeleteWithIterator(iter CompactIterator[T]) {
+	if iter.tree != t {
+		panic("DeleteWithIterator called with iterator not from this tree.")
+	}
+	doAssert(!iter.Limit() && !iter.NegativeLimit())
+	t.deleteNode(iter.current())
+}
+
+// Delete the current item, and return an iterator pointing to its
+// successor, or Limit().
+//
+// REQUIRES: !iter.Limit() && !iter.NegativeLimit()
+func (t *CompactTree[T]) DeleteAndNext(iter CompactIterator[T]) CompactIterator[T] {
+	// The successor keeps its index when it takes the deleted node's
+	// place, see deleteNode.
+	next := iter.Next()
+	t.DeleteWithIterator(iter)
+	return t.iterator(next.node)
+}
+
+// Delete the current item, and return an iterator pointing to its
+// predecessor, or NegativeLimit().
+//
+// REQUIRES: !iter.Limit() && !iter.NegativeLimit()
+func (t *CompactTree[T]) DeleteAndPrev(iter CompactIterator[T]) CompactIterator[T] {
+	prev := iter.Prev()
+	t.DeleteWithIterator(iter)
+	return t.iterator(prev.node)
+}
+
+// Unlink n and free its slot. Unlike DiskTree, a node with two
+// children is replaced by its successor node, not by its item, so that
+// iterators to the successor stay valid.
+func (t *CompactTree[T]) deleteNode(n uint32) {
+	if n == t.minNode {
+		t.minNode = indexNext(t, n)
+	}
+	if n == t.maxNode {
+		if t.maxNode = indexPrev(t, n); t.maxNode == compactNegativeLimit {
+			t.maxNode = compactNil
+		}
+	}
+	// y is the node that leaves its place: n, or n's successor, which
+	// moves to n's place.
+	y := n
+	if t.left(n) != compactNil && t.right(n) != compactNil {
+		y = indexMin(t, t.right(n))
+	}
+	for p := t.parent(y); p != compactNil; p = t.parent(p) {
+		t.mut(p).size--
+	}
+	removed := t.color(y)
+	// x, which may be nil, takes y's place under xp.
+	var x, xp uint32
+	switch {
+	case t.left(n) == compactNil:
+		x, xp = t.right(n), t.parent(n)
+		indexReplaceChild(t, &t.root, n, x)
+	case t.right(n) == compactNil:
+		x, xp = t.left(n), t.parent(n)
+		indexReplaceChild(t, &t.root, n, x)
+	default:
+		x, xp = t.right(y), t.parent(y)
+		if xp == n {
+			xp = y
+		} else {
+			indexReplaceChild(t, &t.root, y, x)
+			t.setRight(y, t.right(n))
+			t.setParent(t.right(y), y)
+		}
+		indexReplaceChild(t, &t.root, n, y)
+		t.setLeft(y, t.left(n))
+		t.setParent(t.left(y), y)
+		t.setColor(y, t.color(n))
+		t.mut(y).size = t.size(n)
+	}
+	if removed == black {
+		indexDeleteFixup(t, &t.root, x, xp)
+	}
+	t.freeNode(n)
+	t.count--
+}
+
+// Split moves the elements of the tree into two new trees: left holds
+// the elements N < key and right the elements N >= key. The larger side
+// keeps the nodes of the tree, and the elements of the smaller one are
+// moved one at a time. The receiver is left empty; iterators on it
+// become invalid.
+func (t *CompactTree[T]) Split(key T) (left, right *CompactTree[T]) {
+	nLeft := t.Rank(key)
+	big, small := t.moveOut(), t.emptyLike()
+	if nLeft <= big.count-nLeft {
+		for i := 0; i < nLeft; i++ {
+			small.link(big.at(big.minNode).item, small.maxNode, 1)
+			big.deleteNode(big.minNode)
+		}
+		return small, big
+	}
+	for big.count > nLeft {
+		small.link(big.at(big.maxNode).item, small.minNode, -1)
+		big.deleteNode(big.maxNode)
+	}
+	return big, small
+}
+
+// JoinCompact moves the elements of left and right into a new tree,
+// and leaves left and right empty. The larger tree keeps its nodes,
+// and the elements of the smaller one are moved one at a time. The new
+// tree uses left's comparison function and iterator checking.
+//
+// REQUIRES: every element of left is smaller than every element of right
+func JoinCompact[T any](left, right *CompactTree[T]) *CompactTree[T] {
+	if left.count > 0 && right.count > 0 && left.compare(left.at(left.maxNode).item, right.at(right.minNode).item) >= 0 {
+		panic("JoinCompact called with overlapping trees")
+	}
+	compare, unchecked := left.compare, left.uncheckedIterators
+	var result *CompactTree[T]
+	if left.count >= right.count {
+		result = left.moveOut()
+		for n := right.minNode; n != compactNil; n = indexNext(right, n) {
+			result.link(right.at(n).item, result.maxNode, 1)
+		}
+	} else {
+		result = right.moveOut()
+		for n := left.maxNode; n != compactNil && n != compactNegativeLimit; n = indexPrev(left, n) {
+			result.link(left.at(n).item, result.minNode, -1)
+		}
+	}
+	left.Reset()
+	right.Reset()
+	result.compare, result.uncheckedIterators = compare, unchecked
+	return result
+}
+
+// Return a new tree holding the nodes of t, and empty t.
+func (t *CompactTree[T]) moveOut() *CompactTree[T] {
+	u := new(CompactTree[T])
+	*u = *t
+	t.Reset()
+	return u
+}
+
+// Create an empty tree with the same comparison and iterator checking
+// as t.
+func (t *CompactTree[T]) emptyLike() *CompactTree[T] {
+	u := NewCompactTree(t.compare)
+	u.uncheckedIterators = t.uncheckedIterators
+	return u
+}
+
+// CompactIterator allows scanning the elements of a CompactTree in sort
+// order, like Iterator, and follows the same invalidation rules.
+type CompactIterator[T any] struct {
+	tree  *CompactTree[T]
+	node  uint32
+	epoch uint64
+	reuse uint32
+}
+
+// Return the current node, and panic if the iterator has been
+// invalidated, see CheckIterators.
+func (iter CompactIterator[T]) current() uint32 {
+	t := iter.tree
+	if t.uncheckedIterators {
+		return iter.node
+	}
+	if iter.epoch != t.epoch {
+		panic(fmt.Errorf("%w: the tree was split, joined or reset since its creation", ErrInvalidIterator))
+	}
+	if iter.node != compactNil && iter.node != compactNegativeLimit && t.at(iter.node).reuse != iter.reuse {
+		panic(fmt.Errorf("%w: its element was deleted", ErrInvalidIterator))
+	}
+	return iter.node
+}
+
+// allow clients to verify iterator is from the right tree.
+func (iter CompactIterator[T]) Tree() *CompactTree[T] {
+	return iter.tree
+}
+
+func (iter CompactIterator[T]) Equal(iter2 CompactIterator[T]) bool {
+	return iter.node == iter2.node
+}
+
+// Check if the iterator points beyond the max element in the tree
+func (iter CompactIterator[T]) Limit() bool {
+	return iter.node == compactNil
+}
+
+// Check if the iterator points to the minimum element in the tree
+func (iter CompactIterator[T]) Min() bool {
+	return iter.node == iter.tree.minNode
+}
+
+// Check if the iterator points to the maximum element in the tree
+func (iter CompactIterator[T]) Max() bool {
+	return iter.node == iter.tree.maxNode
+}
+
+// Check if the iterator points before the minimum element in the tree
+func (iter CompactIterator[T]) NegativeLimit() bool {
+	return iter.node == compactNegativeLimit
+}
+
+// Return the 0-based position of the current element in sort order.
+// Limit() is at position Len() and NegativeLimit() at -1.
+func (iter CompactIterator[T]) Index() int {
+	t := iter.tree
+	n := iter.current()
+	if iter.Limit() {
+		return t.Len()
+	}
+	if iter.NegativeLimit() {
+		return -1
+	}
+	index := int(t.size(t.left(n)))
+	for p := t.parent(n); p != compactNil; n, p = p, t.parent(p) {
+		if t.right(p) == n {
+			index += int(t.size(t.left(p))) + 1
+		}
+	}
+	return index
+}
+
+// Return the current element.
+//
+// REQUIRES: !iter.Limit() && !iter.NegativeLimit()
+func (iter CompactIterator[T]) Item() T {
+	doAssert(!iter.Limit() && !iter.NegativeLimit())
+	return iter.tree.at(iter.current()).item
+}
+
+// Create a new iterator that points to the successor of the current
+// element.
+//
+// REQUIRES: !iter.Limit()
+func (iter CompactIterator[T]) Next() CompactIterator[T] {
+	doAssert(!iter.Limit())
+	n := iter.current()
+	if iter.NegativeLimit() {
+		return iter.tree.Min()
+	}
+	return iter.tree.iterator(indexNext(iter.tree, n))
+}
+
+// Create a new iterator that points to the predecessor of the current
+// element.
+//
+// REQUIRES: !iter.NegativeLimit()
+func (iter CompactIterator[T]) Prev() CompactIterator[T] {
+	doAssert(!iter.NegativeLimit())
+	n := iter.current()
+	if iter.Limit() {
+		return iter.tree.Max()
+	}
+	return iter.tree.iterator(indexPrev(iter.tree, n))
+}
+
+// CompactSnapshot is a read-only view of a CompactTree as of the call
+// to CompactTree.Snapshot, see Snapshot.
+type CompactSnapshot[T any] struct {
+	compactChunks[T]
+	root             uint32
+	minNode, maxNode uint32
+	count            int
+	compare          func(a, b T) int
+}
+
+// Create a read-only view of the current contents of the tree in
+// constant time. The chunks of nodes are shared with the snapshot until
+// the tree next modifies them, see CompactTree.
+//
+// Taking a snapshot does not invalidate the iterators on the tree.
+func (t *CompactTree[T]) Snapshot() *CompactSnapshot[T] {
+	t.gen++
+	t.chunksShared = true
+	return &CompactSnapshot[T]{t.compactChunks, t.root, t.minNode, t.maxNode, t.count, t.compare}
+}
+
+// Return the number of elements in the snapshot.
+func (s *CompactSnapshot[T]) Len() int {
+	return s.count
+}
+
+// A convenience function for finding an element equal to key. Return
+// the zero value of T if not found.
+func (s *CompactSnapshot[T]) Get(key T) T {
+	n, exact := s.findGE(s.root, s.compare, key)
+	if exact {
+		return s.at(n).item
+	}
+	var zero T
+	return zero
+}
+
+// Create an iterator that points to the minimum item in the snapshot.
+// If the snapshot is empty, return Limit().
+func (s *CompactSnapshot[T]) Min() CompactSnapshotIterator[T] {
+	return CompactSnapshotIterator[T]{s, s.minNode}
+}
+
+// Create an iterator that points at the maximum item in the snapshot.
+// If the snapshot is empty, return NegativeLimit().
+func (s *CompactSnapshot[T]) Max() CompactSnapshotIterator[T] {
+	if s.maxNode == compactNil {
+		return s.NegativeLimit()
+	}
+	return CompactSnapshotIterator[T]{s, s.maxNode}
+}
+
+// Create an iterator that points beyond the maximum item.
+func (s *CompactSnapshot[T]) Limit() CompactSnapshotIterator[T] {
+	return CompactSnapshotIterator[T]{s, compactNil}
+}
+
+// Create an iterator that points before the minimum item.
+func (s *CompactSnapshot[T]) NegativeLimit() CompactSnapshotIterator[T] {
+	return CompactSnapshotIterator[T]{s, compactNegativeLimit}
+}
+
+// Find the smallest element N such that N >= key. If no such element
+// is found, return Limit().
+func (s *CompactSnapshot[T]) FindGE(key T) CompactSnapshotIterator[T] {
+	n, _ := s.findGE(s.root, s.compare, key)
+	return CompactSnapshotIterator[T]{s, n}
+}
+
+// Find the largest element N such that N <= key. If no such element
+// is found, return NegativeLimit().
+func (s *CompactSnapshot[T]) FindLE(key T) CompactSnapshotIterator[T] {
+	return CompactSnapshotIterator[T]{s, s.findLE(s.root, s.compare, key)}
+}
+
+// CompactSnapshotIterator allows scanning a CompactSnapshot in sort
+// order. It stays valid for as long as the snapshot is reachable.
+type CompactSnapshotIterator[T any] struct {
+	snapshot *CompactSnapshot[T]
+	node     uint32
+}
+
+func (iter CompactSnapshotIterator[T]) Equal(iter2 CompactSnapshotIterator[T]) bool {
+	return iter.node == iter2.node
+}
+
+// Check if the iterator points beyond the max element
+func (iter CompactSnapshotIterator[T]) Limit() bool {
+	return iter.node == compactNil
+}
+
+// Check if the iterator points before the minumum element
+func (iter CompactSnapshotIterator[T]) NegativeLimit() bool {
+	return iter.node == compactNegativeLimit
+}
+
+// Return the current element.
+//
+// REQUIRES: !iter.Limit() && !iter.NegativeLimit()
+func (iter CompactSnapshotIterator[T]) Item() T {
+	doAssert(!iter.Limit() && !iter.NegativeLimit())
+	return iter.snapshot.at(iter.node).item
+}
+
+// Create a new iterator that points to the successor of the current element.
+//
+// REQUIRES: !iter.Limit()
+func (iter CompactSnapshotIterator[T]) Next() CompactSnapshotIterator[T] {
+	doAssert(!iter.Limit())
+	if iter.NegativeLimit() {
+		return iter.snapshot.Min()
+	}
+	return CompactSnapshotIterator[T]{iter.snapshot, indexNext(iter.snapshot.compactChunks, iter.node)}
+}
+
+// Create a new iterator that points to the predecessor of the current
+// element.
+//
+// REQUIRES: !iter.NegativeLimit()
+func (iter CompactSnapshotIterator[T]) Prev() CompactSnapshotIterator[T] {
+	doAssert(!iter.NegativeLimit())
+	if iter.Limit() {
+		return iter.snapshot.Max()
+	}
+	return CompactSnapshotIterator[T]{iter.snapshot, indexPrev(iter.snapshot.compactChunks, iter.node)}
+}
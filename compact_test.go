@@ -0,0 +1,294 @@
+package rbtree
+
+import (
+	"errors"
+	"math/rand"
+	"slices"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Check the red-black invariants and sizes of t and return its elements
+// in order.
+func validateCompactTree(t *testing.T, tree *CompactTree[int]) []int {
+	var items []int
+	var walk func(n, parent uint32) int
+	walk = func(n, parent uint32) int {
+		if n == compactNil {
+			return 1
+		}
+		assert.Equal(t, parent, tree.parent(n))
+		if tree.color(n) == red {
+			assert.Equal(t, black, tree.color(tree.left(n)))
+			assert.Equal(t, black, tree.color(tree.right(n)))
+		}
+		assert.Equal(t, tree.size(tree.left(n))+tree.size(tree.right(n))+1, tree.size(n))
+		lh := walk(tree.left(n), n)
+		items = append(items, tree.at(n).item)
+		rh := walk(tree.right(n), n)
+		assert.Equal(t, lh, rh)
+		if tree.color(n) == black {
+			lh++
+		}
+		return lh
+	}
+	assert.Equal(t, black, tree.color(compactNil))
+	assert.Equal(t, compactNil, tree.parent(compactNil))
+	assert.Equal(t, black, tree.color(tree.root))
+	walk(tree.root, compactNil)
+	assert.True(t, slices.IsSorted(items))
+	assert.Equal(t, tree.Len(), len(items))
+	if len(items) > 0 {
+		assert.Equal(t, items[0], tree.Min().Item())
+		assert.Equal(t, items[len(items)-1], tree.Max().Item())
+	}
+	return items
+}
+
+func TestCompactTreeRandomized(t *testing.T) {
+	tree := NewOrderedCompactTree[int]()
+	r := rand.New(rand.NewSource(0))
+	o := map[int]bool{}
+	for round := 0; round < 10; round++ {
+		for i := 0; i < 1000; i++ {
+			key := r.Intn(1000)
+			if r.Intn(2) == 0 {
+				assert.Equal(t, o[key], tree.DeleteWithKey(key))
+				delete(o, key)
+			} else {
+				assert.Equal(t, !o[key], tree.Insert(key))
+				o[key] = true
+			}
+		}
+		var want []int
+		for k := range o {
+			want = append(want, k)
+		}
+		slices.Sort(want)
+		assert.Equal(t, want, validateCompactTree(t, tree))
+		assert.Equal(t, want, slices.Collect(tree.All()))
+		slices.Reverse(want)
+		assert.Equal(t, want, slices.Collect(tree.Backward()))
+		slices.Reverse(want)
+
+		for i, item := range want {
+			it := tree.Select(i)
+			assert.Equal(t, item, it.Item())
+			assert.Equal(t, i, it.Index())
+			assert.Equal(t, i, tree.Rank(item))
+			assert.Equal(t, item, tree.Get(item))
+		}
+		key := r.Intn(1000)
+		i, found := slices.BinarySearch(want, key)
+		ge := tree.FindGE(key)
+		assert.Equal(t, i, ge.Index())
+		le := tree.FindLE(key)
+		if found {
+			assert.True(t, le.Equal(ge))
+		} else {
+			assert.Equal(t, i-1, le.Index())
+		}
+	}
+}
+
+func TestCompactTreeIterators(t *testing.T) {
+	tree := NewOrderedCompactTree[int]()
+	assert.True(t, tree.Min().Limit())
+	assert.True(t, tree.Max().NegativeLimit())
+	for i := 0; i < 100; i++ {
+		tree.Insert(i)
+	}
+	assert.True(t, tree.Min().Min())
+	assert.True(t, tree.Max().Max())
+	assert.True(t, tree.Limit().Prev().Equal(tree.Max()))
+	assert.True(t, tree.NegativeLimit().Next().Equal(tree.Min()))
+	assert.True(t, tree.Min().Prev().NegativeLimit())
+	assert.True(t, tree.FindLE(-1).NegativeLimit())
+	assert.True(t, tree.FindGE(100).Limit())
+
+	// Deleting a node with two children moves its successor, which the
+	// iterators to it must follow.
+	iters := map[int]CompactIterator[int]{}
+	for i := 0; i < 100; i++ {
+		iters[i] = tree.FindGE(i)
+	}
+	r := rand.New(rand.NewSource(1))
+	for _, i := range r.Perm(100)[:50] {
+		tree.DeleteWithIterator(iters[i])
+		delete(iters, i)
+		for item, it := range iters {
+			assert.Equal(t, item, it.Item())
+		}
+	}
+	validateCompactTree(t, tree)
+
+	tree.Reset()
+	assert.Equal(t, 0, tree.Len())
+	assert.Len(t, tree.compactChunks, 1)
+	assert.Len(t, tree.compactChunks[0].nodes, 1)
+	tree.Insert(1)
+	assert.Equal(t, []int{1}, validateCompactTree(t, tree))
+}
+
+func TestCompactTreeInvalidIterators(t *testing.T) {
+	tree := NewOrderedCompactTree[int]()
+	for i := 0; i < 10; i++ {
+		tree.Insert(i)
+	}
+	it := tree.FindGE(5)
+	tree.DeleteWithKey(5)
+	assert.True(t, errors.Is(panicError(func() { it.Item() }), ErrInvalidIterator))
+	// The slot is reused by the next insertion.
+	tree.Insert(50)
+	assert.True(t, errors.Is(panicError(func() { it.Next() }), ErrInvalidIterator))
+
+	kept := tree.FindGE(6)
+	assert.Equal(t, 6, tree.DeleteAndPrev(tree.FindGE(7)).Item())
+	assert.Equal(t, 6, kept.Item())
+	assert.Equal(t, 8, tree.DeleteAndNext(kept).Item())
+
+	tree.CheckIterators(false)
+	tree.DeleteWithKey(8)
+	assert.NoError(t, panicError(func() { tree.FindGE(0).Index() }))
+	tree.CheckIterators(true)
+
+	it = tree.Min()
+	tree.Reset()
+	assert.True(t, errors.Is(panicError(func() { it.Item() }), ErrInvalidIterator))
+}
+
+func TestCompactTreeRange(t *testing.T) {
+	tree := NewOrderedTree[int]()
+	compact := NewOrderedCompactTree[int]()
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 300; i++ {
+		k := r.Intn(1000)
+		tree.Insert(k)
+		compact.Insert(k)
+	}
+	for i := 0; i < 500; i++ {
+		lo := r.Intn(1100) - 50
+		hi := lo + r.Intn(200) - 20
+		opts := RangeOptions{
+			Bounds:      Bounds(r.Intn(4)),
+			LoUnbounded: r.Intn(5) == 0,
+			HiUnbounded: r.Intn(5) == 0,
+			Descending:  r.Intn(2) == 0,
+		}
+		assert.Equal(t, slices.Collect(tree.Between(lo, hi, opts)), slices.Collect(compact.Between(lo, hi, opts)), "%d %d %+v", lo, hi, opts)
+		assert.Equal(t, tree.CountRange(lo, hi), compact.CountRange(lo, hi))
+		assert.Equal(t, slices.Collect(tree.AscendGE(lo)), slices.Collect(compact.AscendGE(lo)))
+		assert.Equal(t, slices.Collect(tree.DescendLE(lo)), slices.Collect(compact.DescendLE(lo)))
+	}
+}
+
+func TestCompactTreeSnapshot(t *testing.T) {
+	tree := NewOrderedCompactTree[int]()
+	r := rand.New(rand.NewSource(3))
+	// Enough elements for several chunks.
+	for i := 0; i < 5000; i++ {
+		tree.Insert(r.Intn(10000))
+	}
+	iters := map[int]CompactIterator[int]{}
+	for i := 0; i < 10000; i += 97 {
+		if it := tree.FindGE(i); !it.Limit() {
+			iters[it.Item()] = it
+		}
+	}
+	var snapshots []*CompactSnapshot[int]
+	var contents [][]int
+	for round := 0; round < 5; round++ {
+		snapshots = append(snapshots, tree.Snapshot())
+		contents = append(contents, slices.Collect(tree.All()))
+		for i := 0; i < 1000; i++ {
+			k := r.Intn(10000)
+			if _, kept := iters[k]; kept {
+				continue
+			}
+			if r.Intn(2) == 0 {
+				tree.DeleteWithKey(k)
+			} else {
+				tree.Insert(k)
+			}
+		}
+		validateCompactTree(t, tree)
+		for i, s := range snapshots {
+			var got []int
+			for it := s.Min(); !it.Limit(); it = it.Next() {
+				got = append(got, it.Item())
+			}
+			assert.Equal(t, contents[i], got)
+			assert.Equal(t, len(got), s.Len())
+			got = got[:0]
+			for it := s.Max(); !it.NegativeLimit(); it = it.Prev() {
+				got = append(got, it.Item())
+			}
+			slices.Reverse(got)
+			assert.Equal(t, contents[i], got)
+			k := contents[i][len(contents[i])/2]
+			assert.Equal(t, k, s.Get(k))
+			assert.Equal(t, k, s.FindGE(k).Item())
+			assert.True(t, s.FindLE(k).Equal(s.FindGE(k)))
+		}
+		// Iterators survive the copies of their chunks.
+		for k, it := range iters {
+			assert.Equal(t, k, it.Item())
+		}
+	}
+	assert.True(t, snapshots[0].FindLE(-1).NegativeLimit())
+	assert.True(t, snapshots[0].FindGE(10000).Limit())
+}
+
+func TestCompactTreeSplitJoin(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	for round := 0; round < 20; round++ {
+		tree := NewOrderedCompactTree[int]()
+		for i := 0; i < 500; i++ {
+			tree.Insert(r.Intn(1000))
+		}
+		all := slices.Collect(tree.All())
+		it := tree.Min()
+		key := r.Intn(1100) - 50
+		left, right := tree.Split(key)
+		assert.Equal(t, 0, tree.Len())
+		assert.True(t, errors.Is(panicError(func() { it.Item() }), ErrInvalidIterator))
+		i, _ := slices.BinarySearch(all, key)
+		assert.Equal(t, append([]int(nil), all[:i]...), validateCompactTree(t, left))
+		assert.Equal(t, append([]int(nil), all[i:]...), validateCompactTree(t, right))
+
+		joined := JoinCompact(left, right)
+		assert.Equal(t, 0, left.Len()+right.Len())
+		assert.Equal(t, all, validateCompactTree(t, joined))
+		assert.True(t, joined.Insert(2000))
+		validateCompactTree(t, joined)
+	}
+
+	a, b := NewOrderedCompactTree[int](), NewOrderedCompactTree[int]()
+	a.Insert(5)
+	b.Insert(5)
+	assert.Panics(t, func() { JoinCompact(a, b) })
+}
+
+func TestCompactNodeSize(t *testing.T) {
+	assert.Equal(t, uintptr(20), unsafe.Sizeof(compactNode[struct{}]{}))
+	assert.Equal(t, uintptr(32), unsafe.Sizeof(compactNode[int]{}))
+}
+
+func BenchmarkCompactInsert(b *testing.B) {
+	b.Run("Tree", func(b *testing.B) {
+		b.ReportAllocs()
+		tree := NewOrderedTree[int]()
+		for i := 0; i < b.N; i++ {
+			tree.Insert(i)
+		}
+	})
+	b.Run("CompactTree", func(b *testing.B) {
+		b.ReportAllocs()
+		tree := NewOrderedCompactTree[int]()
+		for i := 0; i < b.N; i++ {
+			tree.Insert(i)
+		}
+	})
+}
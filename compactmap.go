@@ -0,0 +1,233 @@
+package rbtree
+
+import (
+	"cmp"
+	"iter"
+)
+
+// CompactMap like Map, but stored in a CompactTree
+type CompactMap[K, V any] struct {
+	tree *CompactTree[Pair[K, V]]
+}
+
+// NewCompactMap Create a new empty CompactMap. compare orders keys
+// only; values are never compared.
+func NewCompactMap[K, V any](compare func(a, b K) int) CompactMap[K, V] {
+	comparePair := func(a, b Pair[K, V]) int {
+		return compare(a.key, b.key)
+	}
+	return CompactMap[K, V]{tree: NewCompactTree(comparePair)}
+}
+
+// NewOrderedCompactMap Create a new empty CompactMap whose keys are
+// compared with cmp.Compare.
+func NewOrderedCompactMap[K cmp.Ordered, V any]() CompactMap[K, V] {
+	return NewCompactMap[K, V](cmp.Compare[K])
+}
+
+// follow CompactMap operation simple wrapper CompactTree
+
+func (m CompactMap[K, V]) Len() int {
+	return m.tree.Len()
+}
+
+func (m CompactMap[K, V]) Min() CompactMapIterator[K, V] {
+	return CompactMapIterator[K, V]{m.tree.Min()}
+}
+
+func (m CompactMap[K, V]) Max() CompactMapIterator[K, V] {
+	return CompactMapIterator[K, V]{m.tree.Max()}
+}
+
+func (m CompactMap[K, V]) Limit() CompactMapIterator[K, V] {
+	return CompactMapIterator[K, V]{m.tree.Limit()}
+}
+
+func (m CompactMap[K, V]) NegativeLimit() CompactMapIterator[K, V] {
+	return CompactMapIterator[K, V]{m.tree.NegativeLimit()}
+}
+
+func (m CompactMap[K, V]) Find(key K) CompactMapIterator[K, V] {
+	n, found := m.tree.findGE(Pair[K, V]{key: key})
+	if !found {
+		return m.Limit()
+	}
+	return CompactMapIterator[K, V]{m.tree.iterator(n)}
+}
+
+func (m CompactMap[K, V]) FindGE(key K) CompactMapIterator[K, V] {
+	return CompactMapIterator[K, V]{m.tree.FindGE(Pair[K, V]{key: key})}
+}
+
+func (m CompactMap[K, V]) FindLE(key K) CompactMapIterator[K, V] {
+	return CompactMapIterator[K, V]{m.tree.FindLE(Pair[K, V]{key: key})}
+}
+
+// Get from map, see Map.Get
+func (m CompactMap[K, V]) Get(key K) (value V, ok bool) {
+	n, found := m.tree.findGE(Pair[K, V]{key: key})
+	if !found {
+		return value, false
+	}
+	return m.tree.at(n).item.value, true
+}
+
+// Set key and value, create new pair if not exist
+// return true if key already exist
+func (m CompactMap[K, V]) Set(key K, value V) bool {
+	_, found := m.tree.ReplaceOrInsert(Pair[K, V]{key, value})
+	return found
+}
+
+func (m CompactMap[K, V]) DeleteWithKey(key K) bool {
+	return m.tree.DeleteWithKey(Pair[K, V]{key: key})
+}
+
+func (m CompactMap[K, V]) DeleteWithIterator(iter CompactMapIterator[K, V]) {
+	m.tree.DeleteWithIterator(iter.CompactIterator)
+}
+
+// DeleteAndNext delete the current pair, return iterator of the next one
+func (m CompactMap[K, V]) DeleteAndNext(iter CompactMapIterator[K, V]) CompactMapIterator[K, V] {
+	return CompactMapIterator[K, V]{m.tree.DeleteAndNext(iter.CompactIterator)}
+}
+
+// DeleteAndPrev delete the current pair, return iterator of the previous one
+func (m CompactMap[K, V]) DeleteAndPrev(iter CompactMapIterator[K, V]) CompactMapIterator[K, V] {
+	return CompactMapIterator[K, V]{m.tree.DeleteAndPrev(iter.CompactIterator)}
+}
+
+// Rank return the number of keys smaller than key
+func (m CompactMap[K, V]) Rank(key K) int {
+	return m.tree.Rank(Pair[K, V]{key: key})
+}
+
+// Select return iterator of the i'th smallest key, see Tree.Select
+func (m CompactMap[K, V]) Select(i int) CompactMapIterator[K, V] {
+	return CompactMapIterator[K, V]{m.tree.Select(i)}
+}
+
+// CountRange return the number of keys in [lo, hi)
+func (m CompactMap[K, V]) CountRange(lo, hi K) int {
+	return m.tree.CountRange(Pair[K, V]{key: lo}, Pair[K, V]{key: hi})
+}
+
+// Range call fn on every key/value between lo and hi until fn return
+// false, see Tree.Range
+func (m CompactMap[K, V]) Range(lo, hi K, opts RangeOptions, fn func(key K, value V) bool) {
+	m.tree.Range(Pair[K, V]{key: lo}, Pair[K, V]{key: hi}, opts, func(p Pair[K, V]) bool {
+		return fn(p.key, p.value)
+	})
+}
+
+// All return a sequence of the key/value pairs in key order
+func (m CompactMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for p := range m.tree.All() {
+			if !yield(p.key, p.value) {
+				return
+			}
+		}
+	}
+}
+
+// Backward return a sequence of the key/value pairs in reverse key order
+func (m CompactMap[K, V]) Backward() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for p := range m.tree.Backward() {
+			if !yield(p.key, p.value) {
+				return
+			}
+		}
+	}
+}
+
+// Keys return a sequence of the keys in order
+func (m CompactMap[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for p := range m.tree.All() {
+			if !yield(p.key) {
+				return
+			}
+		}
+	}
+}
+
+// Values return a sequence of the values in key order
+func (m CompactMap[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for p := range m.tree.All() {
+			if !yield(p.value) {
+				return
+			}
+		}
+	}
+}
+
+// Between return a sequence of the key/value pairs between lo and hi,
+// see Tree.Range
+func (m CompactMap[K, V]) Between(lo, hi K, opts RangeOptions) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		m.Range(lo, hi, opts, yield)
+	}
+}
+
+// Snapshot Create a read-only view of the map in constant time, see
+// CompactTree.Snapshot
+func (m CompactMap[K, V]) Snapshot() CompactMapSnapshot[K, V] {
+	return CompactMapSnapshot[K, V]{m.tree.Snapshot()}
+}
+
+func (m CompactMap[K, V]) Tree() *CompactTree[Pair[K, V]] {
+	return m.tree
+}
+
+// CompactMapIterator allows scanning map elements in sort order.
+// implement by CompactIterator
+type CompactMapIterator[K, V any] struct {
+	CompactIterator[Pair[K, V]]
+}
+
+func (iter CompactMapIterator[K, V]) Equal(iter2 CompactMapIterator[K, V]) bool {
+	return iter.CompactIterator.Equal(iter2.CompactIterator)
+}
+
+func (iter CompactMapIterator[K, V]) Next() CompactMapIterator[K, V] {
+	return CompactMapIterator[K, V]{iter.CompactIterator.Next()}
+}
+
+func (iter CompactMapIterator[K, V]) Prev() CompactMapIterator[K, V] {
+	return CompactMapIterator[K, V]{iter.CompactIterator.Prev()}
+}
+
+func (iter CompactMapIterator[K, V]) Key() K {
+	return iter.Item().key
+}
+
+func (iter CompactMapIterator[K, V]) Value() V {
+	return iter.Item().value
+}
+
+// CompactMapSnapshot is a read-only view of a CompactMap, see
+// CompactTree.Snapshot
+type CompactMapSnapshot[K, V any] struct {
+	*CompactSnapshot[Pair[K, V]]
+}
+
+// Find the value of key as of the snapshot. The 2nd return value is
+// false iff the key was not in the map.
+func (s CompactMapSnapshot[K, V]) Get(key K) (value V, ok bool) {
+	n, found := s.findGE(s.root, s.compare, Pair[K, V]{key: key})
+	if !found {
+		return value, false
+	}
+	return s.at(n).item.value, true
+}
+
+func (s CompactMapSnapshot[K, V]) FindGE(key K) CompactSnapshotIterator[Pair[K, V]] {
+	return s.CompactSnapshot.FindGE(Pair[K, V]{key: key})
+}
+
+func (s CompactMapSnapshot[K, V]) FindLE(key K) CompactSnapshotIterator[Pair[K, V]] {
+	return s.CompactSnapshot.FindLE(Pair[K, V]{key: key})
+}
@@ -0,0 +1,76 @@
+package rbtree
+
+import (
+	"errors"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompactMap(t *testing.T) {
+	m := NewOrderedCompactMap[int, string]()
+	want := NewOrderedMap[int, string]()
+	r := rand.New(rand.NewSource(5))
+	for i := 0; i < 2000; i++ {
+		k := r.Intn(500)
+		if r.Intn(3) == 0 {
+			assert.Equal(t, want.DeleteWithKey(k), m.DeleteWithKey(k))
+		} else {
+			v := string(rune('a' + r.Intn(26)))
+			assert.Equal(t, want.Set(k, v), m.Set(k, v))
+		}
+	}
+	assert.Equal(t, want.Len(), m.Len())
+	var keys, wantKeys []int
+	var values, wantValues []string
+	for k, v := range m.All() {
+		keys, values = append(keys, k), append(values, v)
+	}
+	for k, v := range want.All() {
+		wantKeys, wantValues = append(wantKeys, k), append(wantValues, v)
+	}
+	assert.Equal(t, wantKeys, keys)
+	assert.Equal(t, wantValues, values)
+
+	for _, k := range wantKeys[:20] {
+		v, ok := m.Get(k)
+		assert.True(t, ok)
+		wv, _ := want.Get(k)
+		assert.Equal(t, wv, v)
+		assert.Equal(t, k, m.Find(k).Key())
+		assert.Equal(t, want.Rank(k), m.Rank(k))
+		assert.Equal(t, k, m.Select(m.Rank(k)).Key())
+	}
+	assert.True(t, m.Find(-1).Limit())
+	assert.Equal(t, want.CountRange(100, 200), m.CountRange(100, 200))
+
+	var got []int
+	m.Range(100, 200, RangeOptions{Bounds: Closed, Descending: true}, func(k int, v string) bool {
+		got = append(got, k)
+		return true
+	})
+	var wantRange []int
+	for k := range want.Between(100, 200, RangeOptions{Bounds: Closed, Descending: true}) {
+		wantRange = append(wantRange, k)
+	}
+	assert.Equal(t, wantRange, got)
+
+	// A snapshot keeps the values as of its creation.
+	s := m.Snapshot()
+	min := m.Min()
+	old := min.Value()
+	m.Set(min.Key(), "changed")
+	assert.Equal(t, "changed", min.Value())
+	v, ok := s.Get(min.Key())
+	assert.True(t, ok)
+	assert.Equal(t, old, v)
+	assert.Equal(t, old, s.FindGE(min.Key()).Item().Value())
+
+	next := m.DeleteAndNext(min)
+	assert.Equal(t, wantKeys[1], next.Key())
+	assert.True(t, errors.Is(panicError(func() { min.Key() }), ErrInvalidIterator))
+	assert.Equal(t, wantKeys[1:], slices.Collect(m.Keys()))
+	assert.Equal(t, wantValues[1:], slices.Collect(m.Values()))
+}
@@ -0,0 +1,645 @@
+package rbtree
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+)
+
+// DiskTree is a red-black tree whose nodes live in fixed-size pages of a
+// local file, of which at most a bounded number are cached in memory.
+// Its API follows Tree's. The tree is written back by Flush and Close,
+// and reopening the file with OpenDiskTree restores it. Since evicted
+// pages are written back at any time, a crash between a change and the
+// next Flush may leave the file inconsistent.
+//
+// Elements are stored encoded by a Codec, in slots of a size fixed when
+// the file is created. Page 0 of the file is a header; the slots for
+// nodes 1, 2, ... fill the following pages in order.
+//
+// Methods that read or write the file do not return errors. Instead,
+// the first I/O or decoding error makes the tree unusable: later
+// operations do nothing, return zero values, and Err, Flush and Close
+// report the error. The file is not written after such an error. An
+// element too large for a slot is not such an error: it is rejected
+// before the tree is touched, see InsertErr.
+//
+// A DiskTree is not safe for concurrent use.
+type DiskTree[T any] struct {
+	file    *os.File
+	cache   *pageCache
+	codec   Codec[T]
+	compare func(a, b T) int
+	err     error
+
+	pageSize     int
+	maxItemSize  int
+	slotSize     int
+	slotsPerPage uint32
+
+	root     uint32
+	count    int
+	nextNode uint32 // the smallest node number never allocated
+	freeList uint32 // freed nodes, linked through their left field
+
+	buf []byte
+}
+
+// DiskTreeOptions configure OpenDiskTree. Zero fields take their
+// default values.
+type DiskTreeOptions struct {
+	// PageSize is the size in bytes of a page of a new file. It is
+	// ignored when opening an existing file. Default 4096.
+	PageSize int
+	// MaxItemSize bounds the encoded size of an element of a new file. It
+	// is ignored when opening an existing file. Default 256.
+	MaxItemSize int
+	// CachePages is the number of pages kept in memory. Default 256.
+	CachePages int
+}
+
+// ErrItemTooLarge is returned by DiskTree.InsertErr for an element
+// whose encoding exceeds MaxItemSize.
+var ErrItemTooLarge = errors.New("rbtree: encoded item exceeds MaxItemSize")
+
+const (
+	diskMagic   = "RBTDISK"
+	diskVersion = 1
+
+	// Layout of the header in page 0.
+	diskHeaderSize     = 40
+	diskOffVersion     = 8
+	diskOffPageSize    = 12
+	diskOffMaxItemSize = 16
+	diskOffRoot        = 20
+	diskOffCount       = 24
+	diskOffNextNode    = 32
+	diskOffFreeList    = 36
+
+	// Layout of a node slot. The encoded item follows the fixed fields.
+	slotOffParent  = 0
+	slotOffLeft    = 4
+	slotOffRight   = 8
+	slotOffColor   = 12
+	slotOffItemLen = 16
+	slotHeaderSize = 20
+
+	// The node number of nil links and of the Limit iterator.
+	diskNil = indexNil
+	// The node number of the NegativeLimit iterator.
+	diskNegativeLimit = indexNegativeLimit
+)
+
+// Open the tree stored in the file at path, or create an empty one if
+// the file does not exist or is empty. Elements are encoded with codec
+// and ordered by compare, which must be the same every time the file is
+// opened. opts may be nil.
+func OpenDiskTree[T any](path string, codec Codec[T], compare func(a, b T) int, opts *DiskTreeOptions) (*DiskTree[T], error) {
+	o := DiskTreeOptions{PageSize: 4096, MaxItemSize: 256, CachePages: 256}
+	if opts != nil {
+		if opts.PageSize > 0 {
+			o.PageSize = opts.PageSize
+		}
+		if opts.MaxItemSize > 0 {
+			o.MaxItemSize = opts.MaxItemSize
+		}
+		if opts.CachePages > 0 {
+			o.CachePages = opts.CachePages
+		}
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	t := &DiskTree[T]{file: file, codec: codec, compare: compare, nextNode: 1}
+	if err := t.readHeader(o); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("rbtree: opening %s: %w", path, err)
+	}
+	t.cache = newPageCache(file, t.pageSize, o.CachePages)
+	return t, nil
+}
+
+// Load the header of the file, or initialize one from o if the file is
+// empty.
+func (t *DiskTree[T]) readHeader(o DiskTreeOptions) error {
+	header := make([]byte, diskHeaderSize)
+	n, err := t.file.ReadAt(header, 0)
+	if n == 0 && errors.Is(err, io.EOF) {
+		t.pageSize, t.maxItemSize = o.PageSize, o.MaxItemSize
+		return t.setGeometry()
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if string(header[:len(diskMagic)]) != diskMagic {
+		return fmt.Errorf("bad magic %q: %w", header[:len(diskMagic)], ErrBadFormat)
+	}
+	if n < diskHeaderSize {
+		return fmt.Errorf("truncated header: %w", ErrBadFormat)
+	}
+	if v := binary.LittleEndian.Uint32(header[diskOffVersion:]); v != diskVersion {
+		return fmt.Errorf("unsupported version %d: %w", v, ErrBadFormat)
+	}
+	t.pageSize = int(binary.LittleEndian.Uint32(header[diskOffPageSize:]))
+	t.maxItemSize = int(binary.LittleEndian.Uint32(header[diskOffMaxItemSize:]))
+	t.root = binary.LittleEndian.Uint32(header[diskOffRoot:])
+	t.count = int(binary.LittleEndian.Uint64(header[diskOffCount:]))
+	t.nextNode = binary.LittleEndian.Uint32(header[diskOffNextNode:])
+	t.freeList = binary.LittleEndian.Uint32(header[diskOffFreeList:])
+	return t.setGeometry()
+}
+
+func (t *DiskTree[T]) setGeometry() error {
+	t.slotSize = slotHeaderSize + t.maxItemSize
+	if t.pageSize < diskHeaderSize || t.pageSize < t.slotSize {
+		return fmt.Errorf("page size %d too small for items of %d bytes: %w", t.pageSize, t.maxItemSize, ErrBadFormat)
+	}
+	t.slotsPerPage = uint32(t.pageSize / t.slotSize)
+	return nil
+}
+
+func (t *DiskTree[T]) writeHeader() error {
+	header := make([]byte, diskHeaderSize)
+	copy(header, diskMagic)
+	binary.LittleEndian.PutUint32(header[diskOffVersion:], diskVersion)
+	binary.LittleEndian.PutUint32(header[diskOffPageSize:], uint32(t.pageSize))
+	binary.LittleEndian.PutUint32(header[diskOffMaxItemSize:], uint32(t.maxItemSize))
+	binary.LittleEndian.PutUint32(header[diskOffRoot:], t.root)
+	binary.LittleEndian.PutUint64(header[diskOffCount:], uint64(t.count))
+	binary.LittleEndian.PutUint32(header[diskOffNextNode:], t.nextNode)
+	binary.LittleEndian.PutUint32(header[diskOffFreeList:], t.freeList)
+	_, err := t.file.WriteAt(header, 0)
+	return err
+}
+
+// Return the error that made the tree unusable, or nil.
+func (t *DiskTree[T]) Err() error {
+	return t.err
+}
+
+// Write all changes to the file and sync it to stable storage.
+func (t *DiskTree[T]) Flush() error {
+	if t.err != nil {
+		return t.err
+	}
+	if err := t.cache.flush(); err != nil {
+		t.err = err
+		return err
+	}
+	// Write the header last, so that it never refers to unwritten pages.
+	if err := t.file.Sync(); err != nil {
+		t.err = err
+		return err
+	}
+	if err := t.writeHeader(); err != nil {
+		t.err = err
+		return err
+	}
+	if err := t.file.Sync(); err != nil {
+		t.err = err
+		return err
+	}
+	return nil
+}
+
+// Flush the tree and close its file. The tree must not be used
+// afterwards.
+func (t *DiskTree[T]) Close() error {
+	err := t.Flush()
+	if cerr := t.file.Close(); err == nil {
+		err = cerr
+	}
+	if t.err == nil {
+		t.err = os.ErrClosed
+	}
+	return err
+}
+
+// diskError carries an error out of the tree algorithms to the public
+// method that started them.
+type diskError struct{ err error }
+
+func (t *DiskTree[T]) fail(err error) {
+	panic(diskError{err})
+}
+
+// Deferred by every public method that touches the file: record the
+// error raised by fail.
+func (t *DiskTree[T]) catch() {
+	if r := recover(); r != nil {
+		e, ok := r.(diskError)
+		if !ok {
+			panic(r)
+		}
+		t.err = e.err
+	}
+}
+
+// Return the slot of node n. The slice is only valid until the next
+// access to another node.
+func (t *DiskTree[T]) slot(n uint32, write bool) []byte {
+	page := 1 + (n-1)/t.slotsPerPage
+	off := int((n-1)%t.slotsPerPage) * t.slotSize
+	data, err := t.cache.get(page, write)
+	if err != nil {
+		t.fail(err)
+	}
+	return data[off : off+t.slotSize]
+}
+
+func (t *DiskTree[T]) link(n uint32, off int) uint32 {
+	if n == diskNil {
+		return diskNil
+	}
+	return binary.LittleEndian.Uint32(t.slot(n, false)[off:])
+}
+
+func (t *DiskTree[T]) setLink(n uint32, off int, to uint32) {
+	if n != diskNil {
+		binary.LittleEndian.PutUint32(t.slot(n, true)[off:], to)
+	}
+}
+
+func (t *DiskTree[T]) parent(n uint32) uint32 { return t.link(n, slotOffParent) }
+func (t *DiskTree[T]) left(n uint32) uint32   { return t.link(n, slotOffLeft) }
+func (t *DiskTree[T]) right(n uint32) uint32  { return t.link(n, slotOffRight) }
+
+func (t *DiskTree[T]) setParent(n, to uint32) { t.setLink(n, slotOffParent, to) }
+func (t *DiskTree[T]) setLeft(n, to uint32)   { t.setLink(n, slotOffLeft, to) }
+func (t *DiskTree[T]) setRight(n, to uint32)  { t.setLink(n, slotOffRight, to) }
+
+// nil links are black.
+func (t *DiskTree[T]) color(n uint32) int {
+	if n == diskNil {
+		return black
+	}
+	return int(t.slot(n, false)[slotOffColor])
+}
+
+func (t *DiskTree[T]) setColor(n uint32, color int) {
+	if n != diskNil {
+		t.slot(n, true)[slotOffColor] = byte(color)
+	}
+}
+
+// The tree keeps nothing about subtrees.
+func (t *DiskTree[T]) rotated(n, c uint32) {}
+
+func (t *DiskTree[T]) item(n uint32) T {
+	s := t.slot(n, false)
+	size := binary.LittleEndian.Uint32(s[slotOffItemLen:])
+	if int(size) > t.maxItemSize {
+		t.fail(fmt.Errorf("node %d holds %d bytes: %w", n, size, ErrBadFormat))
+	}
+	item, err := t.codec.Decode(s[slotHeaderSize : slotHeaderSize+int(size)])
+	if err != nil {
+		t.fail(fmt.Errorf("decoding node %d: %w", n, err))
+	}
+	return item
+}
+
+// Store the encoded item in t.buf into node n.
+func (t *DiskTree[T]) setEncodedItem(n uint32) {
+	s := t.slot(n, true)
+	binary.LittleEndian.PutUint32(s[slotOffItemLen:], uint32(len(t.buf)))
+	copy(s[slotHeaderSize:], t.buf)
+}
+
+// Encode item into t.buf. Return an error wrapping ErrItemTooLarge if
+// the encoding does not fit in a slot.
+func (t *DiskTree[T]) encode(item T) error {
+	t.buf = t.codec.Append(t.buf[:0], item)
+	if len(t.buf) > t.maxItemSize {
+		return fmt.Errorf("%w: %d > %d bytes", ErrItemTooLarge, len(t.buf), t.maxItemSize)
+	}
+	return nil
+}
+
+// Allocate a red node with no links.
+func (t *DiskTree[T]) allocNode() uint32 {
+	n := t.freeList
+	if n != diskNil {
+		t.freeList = t.left(n)
+	} else {
+		if t.nextNode == diskNegativeLimit {
+			t.fail(errors.New("rbtree: DiskTree is full"))
+		}
+		n = t.nextNode
+		t.nextNode++
+	}
+	s := t.slot(n, true)
+	clear(s[:slotHeaderSize])
+	s[slotOffColor] = red
+	return n
+}
+
+func (t *DiskTree[T]) freeNode(n uint32) {
+	t.setLeft(n, t.freeList)
+	t.freeList = n
+}
+
+// Return the number of elements in the tree.
+func (t *DiskTree[T]) Len() int {
+	return t.count
+}
+
+// Return the element equal to key, if any.
+func (t *DiskTree[T]) Get(key T) (item T, ok bool) {
+	defer t.catch()
+	if t.err != nil {
+		return item, false
+	}
+	n, exact := t.findGE(key)
+	if !exact {
+		return item, false
+	}
+	return t.item(n), true
+}
+
+// Find a node whose item >= key, see Tree.findGE.
+func (t *DiskTree[T]) findGE(key T) (uint32, bool) {
+	n, ge := t.root, diskNil
+	for n != diskNil {
+		c := t.compare(key, t.item(n))
+		if c == 0 {
+			return n, true
+		}
+		if c < 0 {
+			ge, n = n, t.left(n)
+		} else {
+			n = t.right(n)
+		}
+	}
+	return ge, false
+}
+
+// Find a node whose item <= key. Return diskNegativeLimit if there is
+// none.
+func (t *DiskTree[T]) findLE(key T) uint32 {
+	n, le := t.root, diskNegativeLimit
+	for n != diskNil {
+		c := t.compare(key, t.item(n))
+		if c == 0 {
+			return n
+		}
+		if c > 0 {
+			le, n = n, t.right(n)
+		} else {
+			n = t.left(n)
+		}
+	}
+	return le
+}
+
+// Find the smallest element N such that N >= key, and return the
+// iterator pointing to the element. If no such element is found,
+// return Limit().
+func (t *DiskTree[T]) FindGE(key T) (it DiskIterator[T]) {
+	it = t.Limit()
+	defer t.catch()
+	if t.err != nil {
+		return it
+	}
+	n, _ := t.findGE(key)
+	return DiskIterator[T]{t, n}
+}
+
+// Find the largest element N such that N <= key, and return the
+// iterator pointing to the element. If no such element is found,
+// return NegativeLimit().
+func (t *DiskTree[T]) FindLE(key T) (it DiskIterator[T]) {
+	it = t.NegativeLimit()
+	defer t.catch()
+	if t.err != nil {
+		return it
+	}
+	return DiskIterator[T]{t, t.findLE(key)}
+}
+
+// Create an iterator that points to the minimum item in the tree. If
+// the tree is empty, return Limit().
+func (t *DiskTree[T]) Min() (it DiskIterator[T]) {
+	it = t.Limit()
+	defer t.catch()
+	if t.err != nil || t.root == diskNil {
+		return it
+	}
+	return DiskIterator[T]{t, indexMin(t, t.root)}
+}
+
+// Create an iterator that points at the maximum item in the tree. If
+// the tree is empty, return NegativeLimit().
+func (t *DiskTree[T]) Max() (it DiskIterator[T]) {
+	it = t.NegativeLimit()
+	defer t.catch()
+	if t.err != nil || t.root == diskNil {
+		return it
+	}
+	return DiskIterator[T]{t, indexMax(t, t.root)}
+}
+
+// Create an iterator that points beyond the maximum item in the tree.
+func (t *DiskTree[T]) Limit() DiskIterator[T] {
+	return DiskIterator[T]{t, diskNil}
+}
+
+// Create an iterator that points before the minimum item in the tree.
+func (t *DiskTree[T]) NegativeLimit() DiskIterator[T] {
+	return DiskIterator[T]{t, diskNegativeLimit}
+}
+
+// Return a sequence of the elements in ascending order. The tree must
+// not be modified while the sequence is being ranged over.
+func (t *DiskTree[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for it := t.Min(); !it.Limit(); it = it.Next() {
+			item := it.Item()
+			if t.err != nil || !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+// Insert an item. If the item is already in the tree, do nothing and
+// return false. Else return true. An item whose encoding exceeds
+// MaxItemSize is not inserted either; InsertErr tells the two apart.
+func (t *DiskTree[T]) Insert(item T) bool {
+	inserted, _ := t.InsertErr(item)
+	return inserted
+}
+
+// Like Insert, but also return why item was not inserted, if not
+// because it was already in the tree: an error wrapping ErrItemTooLarge
+// if its encoding exceeds MaxItemSize, or the error that made the tree
+// unusable, see Err. An oversized item leaves the tree unchanged and
+// usable.
+func (t *DiskTree[T]) InsertErr(item T) (inserted bool, err error) {
+	if t.err != nil {
+		return false, t.err
+	}
+	if err := t.encode(item); err != nil {
+		return false, err
+	}
+	defer func() {
+		if t.err != nil {
+			inserted, err = false, t.err
+		}
+	}()
+	defer t.catch()
+	p, c := diskNil, 0
+	for n := t.root; n != diskNil; {
+		p = n
+		c = t.compare(item, t.item(n))
+		if c == 0 {
+			return false, nil
+		}
+		if c < 0 {
+			n = t.left(n)
+		} else {
+			n = t.right(n)
+		}
+	}
+	n := t.allocNode()
+	t.setEncodedItem(n)
+	t.setParent(n, p)
+	switch {
+	case p == diskNil:
+		t.root = n
+	case c < 0:
+		t.setLeft(p, n)
+	default:
+		t.setRight(p, n)
+	}
+	indexInsertFixup(t, &t.root, n)
+	t.count++
+	return true, nil
+}
+
+// Delete an item with the given key. Return true iff the item was
+// found.
+func (t *DiskTree[T]) DeleteWithKey(key T) (deleted bool) {
+	defer t.catch()
+	if t.err != nil {
+		return false
+	}
+	n, exact := t.findGE(key)
+	if !exact {
+		return false
+	}
+	t.deleteNode(n)
+	return true
+}
+
+// Delete the current item. All iterators on the tree become invalid.
+//
+// REQUIRES: !iter.Limit() && !iter.NegativeLimit()
+func (t *DiskTree[T]) DeleteWithIterator(iter DiskIterator[T]) {
+	if iter.tree != t {
+		panic("DeleteWithIterator called with iterator not from this tree.")
+	}
+	doAssert(!iter.Limit() && !iter.NegativeLimit())
+	defer t.catch()
+	if t.err != nil {
+		return
+	}
+	t.deleteNode(iter.node)
+}
+
+func (t *DiskTree[T]) deleteNode(n uint32) {
+	if t.left(n) != diskNil && t.right(n) != diskNil {
+		// Move the successor's item into n and delete the successor,
+		// which has no left child.
+		s := indexMin(t, t.right(n))
+		src := t.slot(s, false)
+		size := binary.LittleEndian.Uint32(src[slotOffItemLen:])
+		t.buf = append(t.buf[:0], src[slotHeaderSize:slotHeaderSize+int(size)]...)
+		t.setEncodedItem(n)
+		n = s
+	}
+	child := t.left(n)
+	if child == diskNil {
+		child = t.right(n)
+	}
+	p := t.parent(n)
+	indexReplaceChild(t, &t.root, n, child)
+	if t.color(n) == black {
+		indexDeleteFixup(t, &t.root, child, p)
+	}
+	t.freeNode(n)
+	t.count--
+}
+
+// DiskIterator points to an element of a DiskTree, or to one of its
+// limits. Any change to the tree invalidates all its iterators.
+type DiskIterator[T any] struct {
+	tree *DiskTree[T]
+	node uint32
+}
+
+func (iter DiskIterator[T]) Equal(iter2 DiskIterator[T]) bool {
+	return iter.node == iter2.node
+}
+
+// Check if the iterator points beyond the max element in the tree
+func (iter DiskIterator[T]) Limit() bool {
+	return iter.node == diskNil
+}
+
+// Check if the iterator points before the minimum element in the tree
+func (iter DiskIterator[T]) NegativeLimit() bool {
+	return iter.node == diskNegativeLimit
+}
+
+// Return the current element.
+//
+// REQUIRES: !iter.Limit() && !iter.NegativeLimit()
+func (iter DiskIterator[T]) Item() (item T) {
+	doAssert(!iter.Limit() && !iter.NegativeLimit())
+	t := iter.tree
+	defer t.catch()
+	if t.err != nil {
+		return item
+	}
+	return t.item(iter.node)
+}
+
+// Create a new iterator that points to the successor of the current
+// element. After an error, return Limit().
+//
+// REQUIRES: !iter.Limit()
+func (iter DiskIterator[T]) Next() (next DiskIterator[T]) {
+	doAssert(!iter.Limit())
+	t := iter.tree
+	next = t.Limit()
+	defer t.catch()
+	if t.err != nil {
+		return next
+	}
+	if iter.NegativeLimit() {
+		return t.Min()
+	}
+	return DiskIterator[T]{t, indexNext(t, iter.node)}
+}
+
+// Create a new iterator that points to the predecessor of the current
+// element. After an error, return NegativeLimit().
+//
+// REQUIRES: !iter.NegativeLimit()
+func (iter DiskIterator[T]) Prev() (prev DiskIterator[T]) {
+	doAssert(!iter.NegativeLimit())
+	t := iter.tree
+	prev = t.NegativeLimit()
+	defer t.catch()
+	if t.err != nil {
+		return prev
+	}
+	if iter.Limit() {
+		return t.Max()
+	}
+	return DiskIterator[T]{t, indexPrev(t, iter.node)}
+}
@@ -0,0 +1,153 @@
+package rbtree
+
+import (
+	"cmp"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Check the red-black invariants of t and return its elements in order.
+func validateDiskTree(t *testing.T, tree *DiskTree[int]) []int {
+	var items []int
+	var walk func(n, parent uint32) int
+	walk = func(n, parent uint32) int {
+		if n == diskNil {
+			return 1
+		}
+		assert.Equal(t, parent, tree.parent(n))
+		if tree.color(n) == red {
+			assert.Equal(t, black, tree.color(tree.left(n)))
+			assert.Equal(t, black, tree.color(tree.right(n)))
+		}
+		lh := walk(tree.left(n), n)
+		items = append(items, tree.item(n))
+		rh := walk(tree.right(n), n)
+		assert.Equal(t, lh, rh)
+		if tree.color(n) == black {
+			lh++
+		}
+		return lh
+	}
+	assert.Equal(t, black, tree.color(tree.root))
+	walk(tree.root, diskNil)
+	assert.True(t, slices.IsSorted(items))
+	assert.Equal(t, tree.Len(), len(items))
+	return items
+}
+
+func TestDiskTreeRandomized(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree")
+	// Tiny pages and cache force constant eviction.
+	opts := &DiskTreeOptions{PageSize: 128, MaxItemSize: 10, CachePages: 3}
+	tree, err := OpenDiskTree(path, IntCodec[int](), cmp.Compare[int], opts)
+	assert.NoError(t, err)
+	r := rand.New(rand.NewSource(0))
+	o := map[int]bool{}
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 500; i++ {
+			key := r.Intn(1000)
+			if r.Intn(3) == 0 {
+				assert.Equal(t, o[key], tree.DeleteWithKey(key))
+				delete(o, key)
+			} else {
+				assert.Equal(t, !o[key], tree.Insert(key))
+				o[key] = true
+			}
+		}
+		want := slices.Sorted(func(yield func(int) bool) {
+			for k := range o {
+				if !yield(k) {
+					return
+				}
+			}
+		})
+		if len(want) == 0 {
+			want = nil
+		}
+		assert.Equal(t, want, validateDiskTree(t, tree))
+		assert.Equal(t, want, slices.Collect(tree.All()))
+
+		// Reopen with a different cache size.
+		assert.NoError(t, tree.Close())
+		opts.CachePages = round + 1
+		tree, err = OpenDiskTree(path, IntCodec[int](), cmp.Compare[int], opts)
+		assert.NoError(t, err)
+		assert.Equal(t, want, validateDiskTree(t, tree))
+	}
+	assert.NoError(t, tree.Close())
+}
+
+func TestDiskTreeIterators(t *testing.T) {
+	tree, err := OpenDiskTree(filepath.Join(t.TempDir(), "tree"), StringCodec(), cmp.Compare[string], &DiskTreeOptions{PageSize: 256, CachePages: 1, MaxItemSize: 16})
+	assert.NoError(t, err)
+	defer tree.Close()
+	assert.True(t, tree.Min().Limit())
+	assert.True(t, tree.Max().NegativeLimit())
+	for _, s := range []string{"d", "b", "f", "a", "c", "e"} {
+		tree.Insert(s)
+	}
+	assert.Equal(t, "c", tree.FindGE("bb").Item())
+	assert.Equal(t, "b", tree.FindLE("bb").Item())
+	assert.Equal(t, "d", tree.FindGE("d").Item())
+	assert.True(t, tree.FindGE("g").Limit())
+	assert.True(t, tree.FindLE("0").NegativeLimit())
+
+	var got []string
+	for it := tree.Max(); !it.NegativeLimit(); it = it.Prev() {
+		got = append(got, it.Item())
+	}
+	assert.Equal(t, []string{"f", "e", "d", "c", "b", "a"}, got)
+	assert.True(t, tree.Limit().Prev().Equal(tree.Max()))
+	assert.True(t, tree.NegativeLimit().Next().Equal(tree.Min()))
+
+	tree.DeleteWithIterator(tree.FindGE("c"))
+	item, ok := tree.Get("c")
+	assert.False(t, ok)
+	assert.Equal(t, "", item)
+	item, ok = tree.Get("d")
+	assert.True(t, ok)
+	assert.Equal(t, "d", item)
+	assert.Equal(t, 5, tree.Len())
+
+	assert.False(t, tree.Insert("seventeen bytes!!"))
+	inserted, err := tree.InsertErr("seventeen bytes!!")
+	assert.False(t, inserted)
+	assert.True(t, errors.Is(err, ErrItemTooLarge))
+	// The tree is still usable, and earlier inserts reach the file.
+	assert.NoError(t, tree.Err())
+	inserted, err = tree.InsertErr("g")
+	assert.True(t, inserted)
+	assert.NoError(t, err)
+	inserted, err = tree.InsertErr("g")
+	assert.False(t, inserted)
+	assert.NoError(t, err)
+	assert.NoError(t, tree.Flush())
+	assert.Equal(t, 6, tree.Len())
+}
+
+func TestDiskTreeErrorsAreSticky(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree")
+	tree, err := OpenDiskTree(path, IntCodec[int](), cmp.Compare[int], &DiskTreeOptions{PageSize: 64, MaxItemSize: 8, CachePages: 1})
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		tree.Insert(i)
+	}
+	// Break the file under the tree.
+	tree.file.Close()
+	tree.Insert(1000)
+	assert.True(t, errors.Is(tree.Err(), os.ErrClosed))
+	assert.False(t, tree.Insert(1001))
+	assert.True(t, tree.Min().Limit())
+	assert.Error(t, tree.Flush())
+	assert.Error(t, tree.Close())
+
+	assert.NoError(t, os.WriteFile(path, []byte("not a tree, just some text"), 0o644))
+	_, err = OpenDiskTree(path, IntCodec[int](), cmp.Compare[int], nil)
+	assert.True(t, errors.Is(err, ErrBadFormat))
+}
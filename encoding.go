@@ -0,0 +1,289 @@
+package rbtree
+
+import (
+	"bytes"
+	"cmp"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// A Map is encoded in JSON as an object whose members appear in key
+// order when its keys are of a string kind or implement
+// encoding.TextMarshaler, and as an array of [key, value] arrays
+// otherwise. Decoding replaces the contents of the Map, as with gob.
+// It needs the keys' comparison function: it must go into a Map
+// created by NewMap or one of its variants, unless the keys are of a
+// predeclared integer, float or string type, which a zero Map compares
+// with cmp.Compare.
+
+var errUninitializedMap = errors.New("rbtree: decoding into a zero Map whose keys have no natural order")
+
+// Make sure m has a tree to decode into.
+func (m *Map[K, V]) initForDecode() error {
+	if m.tree != nil {
+		return nil
+	}
+	compare := orderedCompare[K]()
+	if compare == nil {
+		return errUninitializedMap
+	}
+	*m = NewMap[K, V](compare)
+	return nil
+}
+
+// Return cmp.Compare for K, or nil if K is not a predeclared ordered
+// type. When the case matches, func(a, b T) int and func(a, b K) int
+// are the same type.
+func orderedCompare[K any]() func(a, b K) int {
+	var compare any
+	switch any(*new(K)).(type) {
+	case int:
+		compare = cmp.Compare[int]
+	case int8:
+		compare = cmp.Compare[int8]
+	case int16:
+		compare = cmp.Compare[int16]
+	case int32:
+		compare = cmp.Compare[int32]
+	case int64:
+		compare = cmp.Compare[int64]
+	case uint:
+		compare = cmp.Compare[uint]
+	case uint8:
+		compare = cmp.Compare[uint8]
+	case uint16:
+		compare = cmp.Compare[uint16]
+	case uint32:
+		compare = cmp.Compare[uint32]
+	case uint64:
+		compare = cmp.Compare[uint64]
+	case uintptr:
+		compare = cmp.Compare[uintptr]
+	case float32:
+		compare = cmp.Compare[float32]
+	case float64:
+		compare = cmp.Compare[float64]
+	case string:
+		compare = cmp.Compare[string]
+	default:
+		return nil
+	}
+	return compare.(func(a, b K) int)
+}
+
+// Replace the contents of m by pairs, which normally come sorted from
+// the encoder.
+func (m *Map[K, V]) replacePairs(pairs []Pair[K, V]) {
+	m.tree.clear()
+	for i := 1; i < len(pairs); i++ {
+		if m.tree.compare(pairs[i-1], pairs[i]) >= 0 {
+			// Encoded in another order, or a key repeats: insert one
+			// pair at a time, so that the last value wins.
+			for _, p := range pairs {
+				m.Set(p.key, p.value)
+			}
+			return
+		}
+	}
+	if err := m.tree.loadSorted(pairs); err != nil {
+		panic(err)
+	}
+}
+
+// Report whether keys of type K are written as JSON object member names.
+func textKeys[K any]() bool {
+	t := reflect.TypeFor[K]()
+	return t.Kind() == reflect.String ||
+		t.Implements(reflect.TypeFor[encoding.TextMarshaler]()) &&
+			reflect.PointerTo(t).Implements(reflect.TypeFor[encoding.TextUnmarshaler]())
+}
+
+func marshalKeyText[K any](key K) (string, error) {
+	if v := reflect.ValueOf(&key).Elem(); v.Kind() == reflect.String {
+		return v.String(), nil
+	}
+	text, err := any(key).(encoding.TextMarshaler).MarshalText()
+	return string(text), err
+}
+
+func unmarshalKeyText[K any](text string) (key K, err error) {
+	if v := reflect.ValueOf(&key).Elem(); v.Kind() == reflect.String {
+		v.SetString(text)
+		return key, nil
+	}
+	err = any(&key).(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+	return key, err
+}
+
+// MarshalJSON implements json.Marshaler, see the encoding above
+func (m Map[K, V]) MarshalJSON() ([]byte, error) {
+	if m.tree == nil {
+		return []byte("null"), nil
+	}
+	text := textKeys[K]()
+	var buf bytes.Buffer
+	if text {
+		buf.WriteByte('{')
+	} else {
+		buf.WriteByte('[')
+	}
+	for n := m.tree.minNode; n != nil; n = n.doNext() {
+		if n != m.tree.minNode {
+			buf.WriteByte(',')
+		}
+		var key []byte
+		var err error
+		if text {
+			var s string
+			if s, err = marshalKeyText(n.item.key); err == nil {
+				key, err = json.Marshal(s)
+			}
+		} else {
+			key, err = json.Marshal(n.item.key)
+		}
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(n.item.value)
+		if err != nil {
+			return nil, err
+		}
+		if text {
+			buf.Write(key)
+			buf.WriteByte(':')
+			buf.Write(value)
+		} else {
+			buf.WriteByte('[')
+			buf.Write(key)
+			buf.WriteByte(',')
+			buf.Write(value)
+			buf.WriteByte(']')
+		}
+	}
+	if text {
+		buf.WriteByte('}')
+	} else {
+		buf.WriteByte(']')
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, see the encoding above.
+// It replaces the contents of m
+func (m *Map[K, V]) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if err := m.initForDecode(); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	text := textKeys[K]()
+	begin, end := json.Delim('['), json.Delim(']')
+	if text {
+		begin, end = '{', '}'
+	}
+	if err := expectDelim(dec, begin); err != nil {
+		return err
+	}
+	var pairs []Pair[K, V]
+	for dec.More() {
+		var key K
+		var value V
+		if text {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if key, err = unmarshalKeyText[K](tok.(string)); err != nil {
+				return err
+			}
+			if err := dec.Decode(&value); err != nil {
+				return err
+			}
+		} else {
+			if err := expectDelim(dec, '['); err != nil {
+				return err
+			}
+			if err := dec.Decode(&key); err != nil {
+				return err
+			}
+			if err := dec.Decode(&value); err != nil {
+				return err
+			}
+			if err := expectDelim(dec, ']'); err != nil {
+				return err
+			}
+		}
+		pairs = append(pairs, Pair[K, V]{key, value})
+	}
+	if err := expectDelim(dec, end); err != nil {
+		return err
+	}
+	m.replacePairs(pairs)
+	return nil
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != want {
+		return fmt.Errorf("rbtree: found %v in JSON, want %v", tok, want)
+	}
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder. The keys and values are sent in
+// key order
+func (m Map[K, V]) GobEncode() ([]byte, error) {
+	var keys []K
+	var values []V
+	if m.tree != nil {
+		keys = make([]K, 0, m.Len())
+		values = make([]V, 0, m.Len())
+		for n := m.tree.minNode; n != nil; n = n.doNext() {
+			keys = append(keys, n.item.key)
+			values = append(values, n.item.value)
+		}
+	}
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	if err := enc.Encode(keys); err != nil {
+		return nil, err
+	}
+	if err := enc.Encode(values); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder. It replaces the contents of m
+func (m *Map[K, V]) GobDecode(data []byte) error {
+	if err := m.initForDecode(); err != nil {
+		return err
+	}
+	var keys []K
+	var values []V
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&keys); err != nil {
+		return err
+	}
+	if err := dec.Decode(&values); err != nil {
+		return err
+	}
+	if len(keys) != len(values) {
+		return fmt.Errorf("rbtree: %d keys but %d values in gob", len(keys), len(values))
+	}
+	pairs := make([]Pair[K, V], len(keys))
+	for i := range keys {
+		pairs[i] = Pair[K, V]{keys[i], values[i]}
+	}
+	m.replacePairs(pairs)
+	return nil
+}
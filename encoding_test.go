@@ -0,0 +1,132 @@
+package rbtree
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/gob"
+	"encoding/json"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapJSONObject(t *testing.T) {
+	m := NewOrderedMap[string, int]()
+	for i, k := range []string{"zeta", "alpha", "mid", "\"quoted\""} {
+		m.Set(k, i)
+	}
+	data, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"\"quoted\"":3,"alpha":1,"mid":2,"zeta":0}`, string(data))
+
+	// Decoding replaces the contents, as GobDecode does.
+	got := NewOrderedMap[string, int]()
+	got.Set("extra", 9)
+	assert.NoError(t, json.Unmarshal(data, &got))
+	validateTree2(got.Tree())
+	assert.Equal(t, 4, got.Len())
+	v, ok := got.Get("mid")
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+	_, ok = got.Get("extra")
+	assert.False(t, ok)
+
+	// Members out of order, and a repeated one whose last value wins.
+	assert.NoError(t, json.Unmarshal([]byte(`{"b":1,"a":2,"b":3}`), &got))
+	validateTree2(got.Tree())
+	assert.Equal(t, 2, got.Len())
+	v, _ = got.Get("b")
+	assert.Equal(t, 3, v)
+}
+
+func TestMapJSONTextMarshalerKeys(t *testing.T) {
+	m := NewMap[netip.Addr, bool](netip.Addr.Compare)
+	m.Set(netip.MustParseAddr("10.0.0.2"), true)
+	m.Set(netip.MustParseAddr("10.0.0.1"), false)
+	data, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"10.0.0.1":false,"10.0.0.2":true}`, string(data))
+
+	got := NewMap[netip.Addr, bool](netip.Addr.Compare)
+	assert.NoError(t, json.Unmarshal(data, &got))
+	v, ok := got.Get(netip.MustParseAddr("10.0.0.2"))
+	assert.True(t, ok)
+	assert.True(t, v)
+}
+
+func TestMapJSONPairs(t *testing.T) {
+	m := NewOrderedMap[int, []string]()
+	m.Set(10, []string{"a"})
+	m.Set(-3, nil)
+	data, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, `[[-3,null],[10,["a"]]]`, string(data))
+
+	// A zero Map with integer keys can be decoded into.
+	var got Map[int, []string]
+	assert.NoError(t, json.Unmarshal(data, &got))
+	validateTree2(got.Tree())
+	v, ok := got.Get(10)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a"}, v)
+
+	// The zero Map compares its keys with cmp.Compare, without reflection.
+	assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() { got.Get(1 << 20) }))
+
+	type id int
+	var ids Map[id, int]
+	assert.Error(t, json.Unmarshal([]byte(`[[1,2]]`), &ids))
+
+	type point struct{ X, Y int }
+	var points Map[point, int]
+	assert.Error(t, json.Unmarshal([]byte(`[[{"X":1,"Y":2},3]]`), &points))
+	assert.Error(t, json.Unmarshal([]byte(`{"1":2}`), &got))
+}
+
+func TestMapJSONInStruct(t *testing.T) {
+	type response struct {
+		Counts Map[string, int] `json:"counts"`
+	}
+	r := response{Counts: NewOrderedMap[string, int]()}
+	r.Counts.Set("b", 2)
+	r.Counts.Set("a", 1)
+	data, err := json.Marshal(r)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"counts":{"a":1,"b":2}}`, string(data))
+
+	var got response
+	assert.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, 2, got.Counts.Len())
+}
+
+func TestMapGob(t *testing.T) {
+	m := NewMap[string, int](func(a, b string) int { return cmp.Compare(b, a) })
+	for i, k := range []string{"a", "b", "c"} {
+		m.Set(k, i)
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, gob.NewEncoder(&buf).Encode(m))
+
+	// Same order: the tree is rebuilt from the sorted stream.
+	reversed := NewMap[string, int](func(a, b string) int { return cmp.Compare(b, a) })
+	reversed.Set("stale", 1)
+	assert.NoError(t, gob.NewDecoder(bytes.NewReader(buf.Bytes())).Decode(&reversed))
+	validateTree2(reversed.Tree())
+	assert.Equal(t, "c", reversed.Min().Key())
+	assert.Equal(t, 3, reversed.Len())
+
+	// A different order falls back to inserting one pair at a time.
+	var natural Map[string, int]
+	assert.NoError(t, gob.NewDecoder(bytes.NewReader(buf.Bytes())).Decode(&natural))
+	validateTree2(natural.Tree())
+	assert.Equal(t, "a", natural.Min().Key())
+	v, _ := natural.Get("b")
+	assert.Equal(t, 1, v)
+
+	var empty bytes.Buffer
+	assert.NoError(t, gob.NewEncoder(&empty).Encode(NewOrderedMap[int, int]()))
+	got := NewOrderedMap[int, int]()
+	assert.NoError(t, gob.NewDecoder(&empty).Decode(&got))
+	assert.Equal(t, 0, got.Len())
+}
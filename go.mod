@@ -2,4 +2,10 @@ module github.com/yasushi-saito/rbtree
 
 require github.com/stretchr/testify v1.4.0
 
-go 1.11
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v2 v2.2.2 // indirect
+)
+
+go 1.23
@@ -0,0 +1,209 @@
+package rbtree
+
+import "math"
+
+// The red-black tree algorithms shared by CompactTree and DiskTree,
+// whose nodes are numbered rather than pointed to. They follow CLRS,
+// like the pointer-based ones in rbtree.go, and only reach the nodes
+// through indexLinks.
+
+const (
+	// The number of nil links, which are black.
+	indexNil uint32 = 0
+	// The number standing for the position before the minimum.
+	indexNegativeLimit uint32 = math.MaxUint32
+)
+
+// indexReader reads the links of the nodes of an index-linked tree.
+type indexReader interface {
+	parent(n uint32) uint32
+	left(n uint32) uint32
+	right(n uint32) uint32
+}
+
+// indexLinks reads and writes the links and colors of the nodes of an
+// index-linked tree. The setters must do nothing for indexNil, and
+// color must return black for it.
+type indexLinks interface {
+	indexReader
+	setParent(n, p uint32)
+	setLeft(n, l uint32)
+	setRight(n, r uint32)
+	color(n uint32) int
+	setColor(n uint32, color int)
+	// Called after a rotation moved n below c, formerly its child, so
+	// that the tree can update what it keeps about each subtree.
+	rotated(n, c uint32)
+}
+
+func indexMin[L indexReader](t L, n uint32) uint32 {
+	for l := t.left(n); l != indexNil; l = t.left(n) {
+		n = l
+	}
+	return n
+}
+
+func indexMax[L indexReader](t L, n uint32) uint32 {
+	for r := t.right(n); r != indexNil; r = t.right(n) {
+		n = r
+	}
+	return n
+}
+
+// Return the successor of n, or indexNil.
+func indexNext[L indexReader](t L, n uint32) uint32 {
+	if r := t.right(n); r != indexNil {
+		return indexMin(t, r)
+	}
+	for p := t.parent(n); p != indexNil; n, p = p, t.parent(p) {
+		if t.left(p) == n {
+			return p
+		}
+	}
+	return indexNil
+}
+
+// Return the predecessor of n, or indexNegativeLimit.
+func indexPrev[L indexReader](t L, n uint32) uint32 {
+	if l := t.left(n); l != indexNil {
+		return indexMax(t, l)
+	}
+	for p := t.parent(n); p != indexNil; n, p = p, t.parent(p) {
+		if t.right(p) == n {
+			return p
+		}
+	}
+	return indexNegativeLimit
+}
+
+// Replace the child n of its parent, or the root, by c.
+func indexReplaceChild[L indexLinks](t L, root *uint32, n, c uint32) {
+	p := t.parent(n)
+	t.setParent(c, p)
+	switch {
+	case p == indexNil:
+		*root = c
+	case t.left(p) == n:
+		t.setLeft(p, c)
+	default:
+		t.setRight(p, c)
+	}
+}
+
+func indexRotateLeft[L indexLinks](t L, root *uint32, n uint32) {
+	r := t.right(n)
+	rl := t.left(r)
+	t.setRight(n, rl)
+	t.setParent(rl, n)
+	indexReplaceChild(t, root, n, r)
+	t.setLeft(r, n)
+	t.setParent(n, r)
+	t.rotated(n, r)
+}
+
+func indexRotateRight[L indexLinks](t L, root *uint32, n uint32) {
+	l := t.left(n)
+	lr := t.right(l)
+	t.setLeft(n, lr)
+	t.setParent(lr, n)
+	indexReplaceChild(t, root, n, l)
+	t.setRight(l, n)
+	t.setParent(n, l)
+	t.rotated(n, l)
+}
+
+// Restore the red-black properties after linking in n, a red leaf.
+func indexInsertFixup[L indexLinks](t L, root *uint32, n uint32) {
+	for t.color(t.parent(n)) == red {
+		p := t.parent(n)
+		g := t.parent(p)
+		if p == t.left(g) {
+			if u := t.right(g); t.color(u) == red {
+				t.setColor(p, black)
+				t.setColor(u, black)
+				t.setColor(g, red)
+				n = g
+				continue
+			}
+			if n == t.right(p) {
+				n, p = p, n
+				indexRotateLeft(t, root, n)
+			}
+			t.setColor(p, black)
+			t.setColor(g, red)
+			indexRotateRight(t, root, g)
+		} else {
+			if u := t.left(g); t.color(u) == red {
+				t.setColor(p, black)
+				t.setColor(u, black)
+				t.setColor(g, red)
+				n = g
+				continue
+			}
+			if n == t.left(p) {
+				n, p = p, n
+				indexRotateRight(t, root, n)
+			}
+			t.setColor(p, black)
+			t.setColor(g, red)
+			indexRotateLeft(t, root, g)
+		}
+	}
+	t.setColor(*root, black)
+}
+
+// Restore the red-black properties after removing a black node, whose
+// place was taken by n, a child of p. n may be indexNil.
+func indexDeleteFixup[L indexLinks](t L, root *uint32, n, p uint32) {
+	for n != *root && t.color(n) == black {
+		if n == t.left(p) {
+			w := t.right(p)
+			if t.color(w) == red {
+				t.setColor(w, black)
+				t.setColor(p, red)
+				indexRotateLeft(t, root, p)
+				w = t.right(p)
+			}
+			if t.color(t.left(w)) == black && t.color(t.right(w)) == black {
+				t.setColor(w, red)
+				n, p = p, t.parent(p)
+				continue
+			}
+			if t.color(t.right(w)) == black {
+				t.setColor(t.left(w), black)
+				t.setColor(w, red)
+				indexRotateRight(t, root, w)
+				w = t.right(p)
+			}
+			t.setColor(w, t.color(p))
+			t.setColor(p, black)
+			t.setColor(t.right(w), black)
+			indexRotateLeft(t, root, p)
+		} else {
+			w := t.left(p)
+			if t.color(w) == red {
+				t.setColor(w, black)
+				t.setColor(p, red)
+				indexRotateRight(t, root, p)
+				w = t.left(p)
+			}
+			if t.color(t.left(w)) == black && t.color(t.right(w)) == black {
+				t.setColor(w, red)
+				n, p = p, t.parent(p)
+				continue
+			}
+			if t.color(t.left(w)) == black {
+				t.setColor(t.right(w), black)
+				t.setColor(w, red)
+				indexRotateLeft(t, root, w)
+				w = t.left(p)
+			}
+			t.setColor(w, t.color(p))
+			t.setColor(p, black)
+			t.setColor(t.left(w), black)
+			indexRotateRight(t, root, p)
+		}
+		n = *root
+	}
+	t.setColor(n, black)
+}
@@ -0,0 +1,266 @@
+package rbtree
+
+import "cmp"
+
+// Interval is a half-open range [Start, End) with an attached value.
+type Interval[K, V any] struct {
+	Start, End K
+	Value      V
+}
+
+// maxEnd is the aggregate kept by an IntervalTree: the largest End in
+// a subtree, and ok=false for an empty subtree.
+type maxEnd[K any] struct {
+	end K
+	ok  bool
+}
+
+// IntervalTree stores intervals ordered by (Start, End) and answers
+// overlap queries in O(log n + k) time for k results. It is a Tree
+// augmented with the maximum End of every subtree, so subtrees whose
+// intervals all end before the query range are skipped.
+//
+// An IntervalTree holds at most one interval for each (Start, End).
+type IntervalTree[K, V any] struct {
+	tree    *Tree[Interval[K, V]]
+	compare func(a, b K) int
+}
+
+// Create a new empty interval tree. compare orders interval endpoints.
+func NewIntervalTree[K, V any](compare func(a, b K) int) *IntervalTree[K, V] {
+	compareInterval := func(a, b Interval[K, V]) int {
+		if c := compare(a.Start, b.Start); c != 0 {
+			return c
+		}
+		return compare(a.End, b.End)
+	}
+	aug := Augmentation[Interval[K, V], maxEnd[K]]{
+		Combine: func(item Interval[K, V], left, right maxEnd[K]) maxEnd[K] {
+			m := maxEnd[K]{item.End, true}
+			if left.ok && compare(left.end, m.end) > 0 {
+				m.end = left.end
+			}
+			if right.ok && compare(right.end, m.end) > 0 {
+				m.end = right.end
+			}
+			return m
+		},
+	}
+	return &IntervalTree[K, V]{
+		tree:    NewAugmentedTree(compareInterval, aug),
+		compare: compare,
+	}
+}
+
+// Create a new empty interval tree whose endpoints are compared with
+// cmp.Compare.
+func NewOrderedIntervalTree[K cmp.Ordered, V any]() *IntervalTree[K, V] {
+	return NewIntervalTree[K, V](cmp.Compare[K])
+}
+
+// Return the number of intervals in the tree.
+func (t *IntervalTree[K, V]) Len() int {
+	return t.tree.Len()
+}
+
+// Insert [start, end) with the given value. If an interval with the
+// same start and end is already in the tree, do nothing and return
+// false. Else return true.
+//
+// REQUIRES: start < end
+func (t *IntervalTree[K, V]) Insert(start, end K, value V) bool {
+	if t.compare(start, end) >= 0 {
+		panic("IntervalTree.Insert called with an empty interval")
+	}
+	return t.tree.Insert(Interval[K, V]{start, end, value})
+}
+
+// Delete the interval [start, end). Return true iff it was found.
+func (t *IntervalTree[K, V]) Delete(start, end K) bool {
+	return t.tree.DeleteWithKey(Interval[K, V]{Start: start, End: end})
+}
+
+// Return the underlying tree, whose iterators scan all intervals in
+// (Start, End) order.
+func (t *IntervalTree[K, V]) Tree() *Tree[Interval[K, V]] {
+	return t.tree
+}
+
+// Create an iterator over the intervals that overlap [lo, hi), that
+// is, intervals with Start < hi and End > lo, in (Start, End) order.
+func (t *IntervalTree[K, V]) Overlapping(lo, hi K) IntervalIterator[K, V] {
+	q := intervalQuery[K]{lo: lo, hi: hi}
+	return t.iterator(t.first(t.tree.root, q), q)
+}
+
+// Create an iterator over the intervals that contain point, that is,
+// intervals with Start <= point < End, in (Start, End) order.
+func (t *IntervalTree[K, V]) Stabbing(point K) IntervalIterator[K, V] {
+	q := intervalQuery[K]{lo: point, hi: point, closed: true}
+	return t.iterator(t.first(t.tree.root, q), q)
+}
+
+// Return the first interval, in (Start, End) order, that overlaps
+// [lo, hi). The 2nd return value is false iff there is none.
+func (t *IntervalTree[K, V]) AnyOverlap(lo, hi K) (Interval[K, V], bool) {
+	iter := t.Overlapping(lo, hi)
+	if iter.Limit() {
+		return Interval[K, V]{}, false
+	}
+	return iter.Item(), true
+}
+
+// IntervalIterator scans the results of an IntervalTree query in
+// (Start, End) order. It is an Iterator of the underlying tree that
+// skips the intervals outside the query, so Limit, NegativeLimit, Item
+// and Index behave as they do for Iterator, and it becomes invalid, and
+// panics with ErrInvalidIterator, in the same cases.
+type IntervalIterator[K, V any] struct {
+	Iterator[Interval[K, V]]
+	tree  *IntervalTree[K, V]
+	query intervalQuery[K]
+}
+
+// Return an iterator for q that points to n, or the limit if n is nil.
+func (t *IntervalTree[K, V]) iterator(n *node[Interval[K, V]], q intervalQuery[K]) IntervalIterator[K, V] {
+	return IntervalIterator[K, V]{t.tree.iterator(n), t, q}
+}
+
+// Check if both iterators point to the same interval.
+func (iter IntervalIterator[K, V]) Equal(iter2 IntervalIterator[K, V]) bool {
+	return iter.Iterator.Equal(iter2.Iterator)
+}
+
+// Create a new iterator that points to the next matching interval.
+//
+// REQUIRES: !iter.Limit()
+func (iter IntervalIterator[K, V]) Next() IntervalIterator[K, V] {
+	doAssert(!iter.Limit())
+	n := iter.current()
+	if iter.NegativeLimit() {
+		return iter.tree.iterator(iter.tree.first(iter.tree.tree.root, iter.query), iter.query)
+	}
+	return iter.tree.iterator(iter.tree.next(n, iter.query), iter.query)
+}
+
+// Create a new iterator that points to the previous matching interval.
+//
+// REQUIRES: !iter.NegativeLimit()
+func (iter IntervalIterator[K, V]) Prev() IntervalIterator[K, V] {
+	doAssert(!iter.NegativeLimit())
+	n := iter.current()
+	if iter.Limit() {
+		n = iter.tree.last(iter.tree.tree.root, iter.query)
+	} else {
+		n = iter.tree.prev(n, iter.query)
+	}
+	if n == nil {
+		return IntervalIterator[K, V]{iter.tree.tree.NegativeLimit(), iter.tree, iter.query}
+	}
+	return iter.tree.iterator(n, iter.query)
+}
+
+// intervalQuery selects intervals with Start < hi (Start <= hi if
+// closed) and End > lo.
+type intervalQuery[K any] struct {
+	lo, hi K
+	closed bool
+}
+
+func (t *IntervalTree[K, V]) startOK(start K, q intervalQuery[K]) bool {
+	c := t.compare(start, q.hi)
+	return c < 0 || (q.closed && c == 0)
+}
+
+func (t *IntervalTree[K, V]) endOK(end K, q intervalQuery[K]) bool {
+	return t.compare(end, q.lo) > 0
+}
+
+// Check if some interval in the subtree n may end after q.lo.
+func (t *IntervalTree[K, V]) mayMatch(n *node[Interval[K, V]], q intervalQuery[K]) bool {
+	if n == nil {
+		return false
+	}
+	m := *aggOf[maxEnd[K]](n)
+	return m.ok && t.endOK(m.end, q)
+}
+
+// Return the first node in the subtree n that matches q, or nil.
+func (t *IntervalTree[K, V]) first(n *node[Interval[K, V]], q intervalQuery[K]) *node[Interval[K, V]] {
+	if !t.mayMatch(n, q) {
+		return nil
+	}
+	if m := t.first(n.left, q); m != nil {
+		return m
+	}
+	if !t.startOK(n.item.Start, q) {
+		// n and everything to its right start too late.
+		return nil
+	}
+	if t.endOK(n.item.End, q) {
+		return n
+	}
+	return t.first(n.right, q)
+}
+
+// Return the first node after n that matches q, or nil.
+func (t *IntervalTree[K, V]) next(n *node[Interval[K, V]], q intervalQuery[K]) *node[Interval[K, V]] {
+	if m := t.first(n.right, q); m != nil {
+		return m
+	}
+	for ; n.parent != nil; n = n.parent {
+		if !n.isLeftChild() {
+			continue
+		}
+		p := n.parent
+		if !t.startOK(p.item.Start, q) {
+			return nil
+		}
+		if t.endOK(p.item.End, q) {
+			return p
+		}
+		if m := t.first(p.right, q); m != nil {
+			return m
+		}
+	}
+	return nil
+}
+
+// Return the last node in the subtree n that matches q, or nil.
+func (t *IntervalTree[K, V]) last(n *node[Interval[K, V]], q intervalQuery[K]) *node[Interval[K, V]] {
+	if !t.mayMatch(n, q) {
+		return nil
+	}
+	if !t.startOK(n.item.Start, q) {
+		// n and everything to its right start too late.
+		return t.last(n.left, q)
+	}
+	if m := t.last(n.right, q); m != nil {
+		return m
+	}
+	if t.endOK(n.item.End, q) {
+		return n
+	}
+	return t.last(n.left, q)
+}
+
+// Return the last node before n that matches q, or nil. n starts early
+// enough for q, and so does everything before it.
+func (t *IntervalTree[K, V]) prev(n *node[Interval[K, V]], q intervalQuery[K]) *node[Interval[K, V]] {
+	if m := t.last(n.left, q); m != nil {
+		return m
+	}
+	for ; n.parent != nil; n = n.parent {
+		if n.isLeftChild() {
+			continue
+		}
+		p := n.parent
+		if t.endOK(p.item.End, q) {
+			return p
+		}
+		if m := t.last(p.left, q); m != nil {
+			return m
+		}
+	}
+	return nil
+}
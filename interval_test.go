@@ -0,0 +1,131 @@
+package rbtree
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testInterval struct{ start, end int }
+
+func collectIntervals(iter IntervalIterator[int, string]) []testInterval {
+	var out []testInterval
+	for ; !iter.Limit(); iter = iter.Next() {
+		out = append(out, testInterval{iter.Item().Start, iter.Item().End})
+	}
+	return out
+}
+
+// Collect the results of a query from the last one back.
+func collectIntervalsBackward(iter IntervalIterator[int, string]) []testInterval {
+	for !iter.Limit() {
+		iter = iter.Next()
+	}
+	var out []testInterval
+	for iter = iter.Prev(); !iter.NegativeLimit(); iter = iter.Prev() {
+		out = append(out, testInterval{iter.Item().Start, iter.Item().End})
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+func bruteForceIntervals(set map[testInterval]bool, match func(testInterval) bool) []testInterval {
+	var out []testInterval
+	for iv := range set {
+		if match(iv) {
+			out = append(out, iv)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].start != out[j].start {
+			return out[i].start < out[j].start
+		}
+		return out[i].end < out[j].end
+	})
+	return out
+}
+
+func TestIntervalTreeRandomized(t *testing.T) {
+	tree := NewOrderedIntervalTree[int, string]()
+	set := map[testInterval]bool{}
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 2000; i++ {
+		start := r.Intn(1000)
+		iv := testInterval{start, start + 1 + r.Intn(50)}
+		if r.Intn(3) > 0 {
+			assert.Equal(t, !set[iv], tree.Insert(iv.start, iv.end, "v"))
+			set[iv] = true
+		} else {
+			assert.Equal(t, set[iv], tree.Delete(iv.start, iv.end))
+			delete(set, iv)
+		}
+
+		lo := r.Intn(1100) - 50
+		hi := lo + r.Intn(30)
+		assert.Equal(t,
+			bruteForceIntervals(set, func(iv testInterval) bool { return iv.start < hi && iv.end > lo }),
+			collectIntervals(tree.Overlapping(lo, hi)), "Overlapping(%d, %d)", lo, hi)
+		assert.Equal(t,
+			bruteForceIntervals(set, func(iv testInterval) bool { return iv.start <= lo && lo < iv.end }),
+			collectIntervals(tree.Stabbing(lo)), "Stabbing(%d)", lo)
+		assert.Equal(t,
+			collectIntervals(tree.Overlapping(lo, hi)),
+			collectIntervalsBackward(tree.Overlapping(lo, hi)), "Overlapping(%d, %d) backward", lo, hi)
+	}
+	assert.Equal(t, len(set), tree.Len())
+}
+
+func TestIntervalTreeQueries(t *testing.T) {
+	tree := NewOrderedIntervalTree[int, string]()
+	assert.True(t, tree.Insert(10, 20, "a"))
+	assert.True(t, tree.Insert(15, 16, "b"))
+	assert.True(t, tree.Insert(30, 40, "c"))
+	assert.False(t, tree.Insert(10, 20, "dup"))
+
+	iv, ok := tree.AnyOverlap(18, 35)
+	assert.True(t, ok)
+	assert.Equal(t, Interval[int, string]{10, 20, "a"}, iv)
+	_, ok = tree.AnyOverlap(20, 30)
+	assert.False(t, ok, "half-open intervals touching at an endpoint do not overlap")
+
+	assert.Equal(t, []testInterval{{10, 20}, {15, 16}}, collectIntervals(tree.Stabbing(15)))
+	assert.Empty(t, collectIntervals(tree.Stabbing(40)))
+	assert.Panics(t, func() { tree.Insert(5, 5, "empty") })
+}
+
+func TestIntervalIterator(t *testing.T) {
+	tree := NewOrderedIntervalTree[int, string]()
+	tree.Insert(10, 20, "a")
+	tree.Insert(15, 16, "b")
+	tree.Insert(30, 40, "c")
+	tree.Insert(35, 36, "d")
+
+	iter := tree.Overlapping(12, 36)
+	assert.Equal(t, "a", iter.Item().Value)
+	assert.Equal(t, 0, iter.Index())
+	first := iter
+	iter = iter.Next().Next()
+	assert.Equal(t, "c", iter.Item().Value)
+	assert.Equal(t, "b", iter.Prev().Item().Value)
+	assert.True(t, iter.Prev().Prev().Equal(first))
+	assert.True(t, first.Prev().NegativeLimit())
+	assert.True(t, first.Prev().Next().Equal(first))
+
+	// Both c and d contain 35.
+	stab := tree.Stabbing(35)
+	assert.Equal(t, "c", stab.Item().Value)
+	assert.True(t, stab.Next().Next().Limit())
+	assert.Equal(t, "d", stab.Next().Next().Prev().Item().Value)
+
+	// An iterator to a deleted interval is detected, as for Iterator.
+	tree.Delete(30, 40)
+	assert.True(t, errors.Is(panicError(func() { iter.Item() }), ErrInvalidIterator))
+	assert.True(t, errors.Is(panicError(func() { iter.Next() }), ErrInvalidIterator))
+	assert.True(t, errors.Is(panicError(func() { iter.Prev() }), ErrInvalidIterator))
+	assert.Equal(t, "a", first.Item().Value)
+}
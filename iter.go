@@ -0,0 +1,71 @@
+package rbtree
+
+import "iter"
+
+// The sequences below walk the tree at the time they are ranged over,
+// not when they are created. The tree must not be modified while a
+// sequence is being ranged over.
+
+// Return a sequence of the elements in ascending order.
+func (root *Tree[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for n := root.minNode; n != nil && yield(n.item); n = n.doNext() {
+		}
+	}
+}
+
+// Return a sequence of the elements in descending order.
+func (root *Tree[T]) Backward() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for n := root.maxNode; n != nil && yield(n.item); n = n.doPrev() {
+		}
+	}
+}
+
+// Return a sequence of the elements between lo and hi, as selected by
+// opts, see Range.
+func (root *Tree[T]) Between(lo, hi T, opts RangeOptions) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		root.Range(lo, hi, opts, yield)
+	}
+}
+
+// All return a sequence of the key/value pairs in key order
+func (m Map[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for n := m.tree.minNode; n != nil && yield(n.item.key, n.item.value); n = n.doNext() {
+		}
+	}
+}
+
+// Backward return a sequence of the key/value pairs in reverse key order
+func (m Map[K, V]) Backward() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for n := m.tree.maxNode; n != nil && yield(n.item.key, n.item.value); n = n.doPrev() {
+		}
+	}
+}
+
+// Keys return a sequence of the keys in order
+func (m Map[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for n := m.tree.minNode; n != nil && yield(n.item.key); n = n.doNext() {
+		}
+	}
+}
+
+// Values return a sequence of the values in key order
+func (m Map[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for n := m.tree.minNode; n != nil && yield(n.item.value); n = n.doNext() {
+		}
+	}
+}
+
+// Between return a sequence of the key/value pairs between lo and hi,
+// see Tree.Range
+func (m Map[K, V]) Between(lo, hi K, opts RangeOptions) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		m.Range(lo, hi, opts, yield)
+	}
+}
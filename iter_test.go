@@ -0,0 +1,54 @@
+package rbtree
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTreeSeq(t *testing.T) {
+	tree := NewOrderedTree[int]()
+	assert.Empty(t, slices.Collect(tree.All()))
+	assert.Empty(t, slices.Collect(tree.Backward()))
+	for _, k := range []int{5, 1, 4, 2, 3} {
+		tree.Insert(k)
+	}
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, slices.Collect(tree.All()))
+	assert.Equal(t, []int{5, 4, 3, 2, 1}, slices.Collect(tree.Backward()))
+	assert.Equal(t, []int{2, 3}, slices.Collect(tree.Between(2, 4, RangeOptions{})))
+	assert.Equal(t, []int{4, 3}, slices.Collect(tree.Between(2, 4, RangeOptions{Bounds: LeftOpen, Descending: true})))
+
+	got := []int{}
+	for item := range tree.All() {
+		if item == 3 {
+			break
+		}
+		got = append(got, item)
+	}
+	assert.Equal(t, []int{1, 2}, got)
+}
+
+func TestMapSeq(t *testing.T) {
+	m := NewOrderedMap[string, int]()
+	m.Set("b", 2)
+	m.Set("a", 1)
+	m.Set("c", 3)
+	assert.Equal(t, map[string]int{"a": 1, "b": 2, "c": 3}, maps.Collect(m.All()))
+	assert.Equal(t, []string{"a", "b", "c"}, slices.Collect(m.Keys()))
+	assert.Equal(t, []int{1, 2, 3}, slices.Collect(m.Values()))
+
+	keys := []string{}
+	for k, v := range m.Backward() {
+		keys = append(keys, k)
+		assert.Equal(t, int(k[0]-'a'+1), v)
+	}
+	assert.Equal(t, []string{"c", "b", "a"}, keys)
+
+	keys = keys[:0]
+	for k := range m.Between("b", "", RangeOptions{HiUnbounded: true}) {
+		keys = append(keys, k)
+	}
+	assert.Equal(t, []string{"b", "c"}, keys)
+}
@@ -1,96 +1,118 @@
 package rbtree
 
-type Pair struct {
-	key Item
-	value Item
+import "cmp"
+
+// Pair is a key/value entry stored in a Map node.
+type Pair[K, V any] struct {
+	key   K
+	value V
+}
+
+func (p Pair[K, V]) Key() K {
+	return p.key
+}
+
+func (p Pair[K, V]) Value() V {
+	return p.value
 }
 
-// Map like map[interface{}]interface{}
+// Map like map[K]V kept in key order
 // implement by Tree
-type Map struct {
-	tree *Tree
+type Map[K, V any] struct {
+	tree *Tree[Pair[K, V]]
 }
 
-// NewMap Create a new empty Map
-func NewMap(compare CompareFunc) Map {
-	comparePair := func(a, b interface{}) int {
-		pa := a.(Pair)
-		pb := b.(Pair)
-		return compare(pa.key, pb.key)
+// NewMap Create a new empty Map. compare orders keys only; values
+// are never compared.
+func NewMap[K, V any](compare func(a, b K) int) Map[K, V] {
+	comparePair := func(a, b Pair[K, V]) int {
+		return compare(a.key, b.key)
 	}
-	return Map{tree: NewTree(comparePair)}
+	return Map[K, V]{tree: NewTree(comparePair)}
+}
+
+// NewItemMap Create a new empty Map of untyped keys and values, like
+// NewMap did before Map took type parameters.
+//
+// Deprecated: use NewMap[K, V] with the actual key and value types.
+func NewItemMap(compare CompareFunc) Map[Item, Item] {
+	return NewMap[Item, Item](compare)
+}
+
+// NewOrderedMap Create a new empty Map whose keys are compared with
+// cmp.Compare.
+func NewOrderedMap[K cmp.Ordered, V any]() Map[K, V] {
+	return NewMap[K, V](cmp.Compare[K])
 }
 
 // follow Map operation simple wrapper Tree
 
-func (m Map) Len() int {
+func (m Map[K, V]) Len() int {
 	return m.tree.Len()
 }
 
-func (m Map) Min() MapIterator {
-	return MapIterator{Iterator: m.tree.Min()}
+func (m Map[K, V]) Min() MapIterator[K, V] {
+	return MapIterator[K, V]{Iterator: m.tree.Min()}
 }
 
-func (m Map) Max() MapIterator {
-	return MapIterator{Iterator: m.tree.Max()}
+func (m Map[K, V]) Max() MapIterator[K, V] {
+	return MapIterator[K, V]{Iterator: m.tree.Max()}
 }
 
-func (m Map) Limit() MapIterator {
-	return MapIterator{Iterator: m.tree.Limit()}
+func (m Map[K, V]) Limit() MapIterator[K, V] {
+	return MapIterator[K, V]{Iterator: m.tree.Limit()}
 }
 
-func (m Map) NegativeLimit() MapIterator {
-	return MapIterator{Iterator: m.tree.NegativeLimit()}
+func (m Map[K, V]) NegativeLimit() MapIterator[K, V] {
+	return MapIterator[K, V]{Iterator: m.tree.NegativeLimit()}
 }
 
-func (m Map) Find(key Item) MapIterator {
-	pair := Pair{key, nil}
-	n, found := m.tree.findGE(pair)
+func (m Map[K, V]) Find(key K) MapIterator[K, V] {
+	n, found := m.tree.findGE(Pair[K, V]{key: key})
 	if !found {
-		return MapIterator{Iterator{m.tree, nil}}
+		return MapIterator[K, V]{m.tree.Limit()}
 	}
-	return MapIterator{Iterator{m.tree, n}}
+	return MapIterator[K, V]{m.tree.iterator(n)}
 }
 
-func (m Map) FindGE(key Item) MapIterator {
-	pair := Pair{key, nil}
-	return MapIterator{Iterator: m.tree.FindGE(pair)}
+func (m Map[K, V]) FindGE(key K) MapIterator[K, V] {
+	return MapIterator[K, V]{Iterator: m.tree.FindGE(Pair[K, V]{key: key})}
 }
 
-func (m Map) FindLE(key Item) MapIterator {
-	pair := Pair{key, nil}
-	return MapIterator{Iterator: m.tree.FindLE(pair)}
+func (m Map[K, V]) FindLE(key K) MapIterator[K, V] {
+	return MapIterator[K, V]{Iterator: m.tree.FindLE(Pair[K, V]{key: key})}
 }
 
 // Get from map
 // value: value find with key
-// ok: ture if key found
-func (m Map) Get(key Item) (value Item, ok bool) {
-	pair := Pair{key, nil}
-	n, found := m.tree.findGE(pair)
+// ok: true if key found
+func (m Map[K, V]) Get(key K) (value V, ok bool) {
+	n, found := m.tree.findGE(Pair[K, V]{key: key})
 	if !found {
-		return nil, false
+		return value, false
 	}
-	pair = n.item.(Pair)
-	return pair.value, true
+	return n.item.value, true
 }
 
 // Set key and value, create new pair if not exist
 // return true if key already exist
-func (m Map) Set(key Item, value Item) bool {
-	pair := Pair{key, value}
-	n, found := m.tree.findGE(pair)
-	if found {
-		n.item = pair
-	} else {
-		m.tree.Insert(pair)
-	}
+func (m Map[K, V]) Set(key K, value V) bool {
+	_, found := m.tree.ReplaceOrInsert(Pair[K, V]{key, value})
 	return found
 }
 
-func (m Map) DeleteWithKey(key Item) bool {
-	pair := Pair{key, nil}
-	n, found := m.tree.findGE(pair)
+// Update insert, modify or delete key in one descent. fn get the
+// current value and whether key exist, return the new value and whether
+// to keep key. fn must not modify the map
+func (m Map[K, V]) Update(key K, fn func(old V, exists bool) (new V, keep bool)) {
+	m.tree.update(Pair[K, V]{key: key}, func(old Pair[K, V], exists bool) (Pair[K, V], bool) {
+		value, keep := fn(old.value, exists)
+		return Pair[K, V]{key, value}, keep
+	})
+}
+
+func (m Map[K, V]) DeleteWithKey(key K) bool {
+	n, found := m.tree.findGE(Pair[K, V]{key: key})
 	if found {
 		m.tree.doDelete(n)
 		return true
@@ -98,40 +120,91 @@ func (m Map) DeleteWithKey(key Item) bool {
 	return false
 }
 
-func (m Map) DeleteWithIterator(iter MapIterator) {
+func (m Map[K, V]) DeleteWithIterator(iter MapIterator[K, V]) {
 	m.tree.DeleteWithIterator(iter.Iterator)
 }
 
-func (m Map) Tree() *Tree {
+// DeleteAndNext delete the current pair, return iterator of the next one
+func (m Map[K, V]) DeleteAndNext(iter MapIterator[K, V]) MapIterator[K, V] {
+	return MapIterator[K, V]{m.tree.DeleteAndNext(iter.Iterator)}
+}
+
+// DeleteAndPrev delete the current pair, return iterator of the previous one
+func (m Map[K, V]) DeleteAndPrev(iter MapIterator[K, V]) MapIterator[K, V] {
+	return MapIterator[K, V]{m.tree.DeleteAndPrev(iter.Iterator)}
+}
+
+// DeleteIf delete every pair that pred return true for
+// return the number deleted, pred must not modify the map
+func (m Map[K, V]) DeleteIf(pred func(key K, value V) bool) int {
+	return m.tree.DeleteIf(func(p Pair[K, V]) bool { return pred(p.key, p.value) })
+}
+
+// Retain delete every pair that keep return false for
+// return the number deleted, see DeleteIf
+func (m Map[K, V]) Retain(keep func(key K, value V) bool) int {
+	return m.tree.Retain(func(p Pair[K, V]) bool { return keep(p.key, p.value) })
+}
+
+// Rank return the number of keys < key
+func (m Map[K, V]) Rank(key K) int {
+	return m.tree.Rank(Pair[K, V]{key: key})
+}
+
+// Select return iterator of the i'th smallest key, see Tree.Select
+func (m Map[K, V]) Select(i int) MapIterator[K, V] {
+	return MapIterator[K, V]{Iterator: m.tree.Select(i)}
+}
+
+// CountRange return the number of keys in [lo, hi)
+func (m Map[K, V]) CountRange(lo, hi K) int {
+	return m.tree.CountRange(Pair[K, V]{key: lo}, Pair[K, V]{key: hi})
+}
+
+// DeleteRange remove the keys between lo and hi, see Tree.DeleteRange
+// return the number of keys removed
+func (m Map[K, V]) DeleteRange(lo, hi K, bounds Bounds) int {
+	return m.tree.DeleteRange(Pair[K, V]{key: lo}, Pair[K, V]{key: hi}, bounds)
+}
+
+// Range call fn on every key/value between lo and hi until fn return
+// false, see Tree.Range
+func (m Map[K, V]) Range(lo, hi K, opts RangeOptions, fn func(key K, value V) bool) {
+	m.tree.Range(Pair[K, V]{key: lo}, Pair[K, V]{key: hi}, opts, func(p Pair[K, V]) bool {
+		return fn(p.key, p.value)
+	})
+}
+
+func (m Map[K, V]) Tree() *Tree[Pair[K, V]] {
 	return m.tree
 }
 
 // MapIterator allows scanning map elements in sort order.
 // implement by Iterator
-type MapIterator struct {
-	Iterator
+type MapIterator[K, V any] struct {
+	Iterator[Pair[K, V]]
 }
 
-func (iter MapIterator) Equal(iter2 MapIterator) bool {
+func (iter MapIterator[K, V]) Equal(iter2 MapIterator[K, V]) bool {
 	return iter.Iterator.Equal(iter2.Iterator)
 }
 
-func (iter MapIterator) Next() MapIterator {
-	return MapIterator{Iterator: iter.Iterator.Next()}
+func (iter MapIterator[K, V]) Next() MapIterator[K, V] {
+	return MapIterator[K, V]{Iterator: iter.Iterator.Next()}
 }
 
-func (iter MapIterator) Prev() MapIterator {
-	return MapIterator{Iterator: iter.Iterator.Prev()}
+func (iter MapIterator[K, V]) Prev() MapIterator[K, V] {
+	return MapIterator[K, V]{Iterator: iter.Iterator.Prev()}
 }
 
-func (iter MapIterator) Item() Pair {
-	return iter.Iterator.Item().(Pair)
+func (iter MapIterator[K, V]) Item() Pair[K, V] {
+	return iter.Iterator.Item()
 }
 
-func (iter MapIterator) Key() Item {
-	return iter.Item().key
+func (iter MapIterator[K, V]) Key() K {
+	return iter.Iterator.Item().key
 }
 
-func (iter MapIterator) Value() Item {
-	return iter.Item().value
-}
\ No newline at end of file
+func (iter MapIterator[K, V]) Value() V {
+	return iter.Iterator.Item().value
+}
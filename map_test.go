@@ -6,8 +6,8 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func testNewIntMap() Map {
-	return NewMap(func(i1, i2 Item) int {
+func testNewIntMap() Map[Item, Item] {
+	return NewItemMap(func(i1, i2 Item) int {
 		return int(i1.(int)) - int(i2.(int))
 	})
 }
@@ -103,4 +103,99 @@ func TestFind(t *testing.T) {
 	iter = m.FindLE(10)
 	assert.EqualValues(t, iter.Key(), 9)
 	assert.EqualValues(t, m.FindLE(-1), m.NegativeLimit())
-}
\ No newline at end of file
+}
+
+func TestTypedMap(t *testing.T) {
+	m := NewOrderedMap[string, int]()
+	assert.False(t, m.Set("b", 2))
+	assert.False(t, m.Set("a", 1))
+	assert.False(t, m.Set("c", 3))
+	assert.True(t, m.Set("b", 20))
+
+	val, ok := m.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 20, val)
+	val, ok = m.Get("z")
+	assert.False(t, ok)
+	assert.Equal(t, 0, val)
+
+	keys := []string{}
+	for iter := m.Min(); !iter.Limit(); iter = iter.Next() {
+		keys = append(keys, iter.Key())
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, keys)
+	assert.Equal(t, "c", m.FindGE("bb").Key())
+	assert.Equal(t, 20, m.FindLE("bb").Value())
+	assert.Equal(t, "a", m.Find("a").Item().Key())
+}
+
+func TestTypedMapReadsDoNotAllocate(t *testing.T) {
+	m := NewOrderedMap[int64, string]()
+	for i := int64(0); i < 1000; i += 2 {
+		m.Set(i, "v")
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		for i := int64(0); i < 1000; i += 7 {
+			m.Get(i)
+			m.Find(i)
+			m.FindGE(i).Key()
+			m.FindLE(i)
+		}
+	})
+	assert.Zero(t, allocs)
+}
+
+func TestMapOrderStatistics(t *testing.T) {
+	m := NewOrderedMap[int, string]()
+	for i := 0; i < 100; i += 10 {
+		m.Set(i, "v")
+	}
+	assert.Equal(t, 3, m.Rank(25))
+	assert.Equal(t, 3, m.Rank(30))
+	assert.Equal(t, 40, m.Select(4).Key())
+	assert.Equal(t, 4, m.Find(40).Index())
+	assert.Equal(t, 2, m.CountRange(15, 40))
+	assert.Equal(t, 0, m.CountRange(40, 15))
+}
+
+func TestMapUpdate(t *testing.T) {
+	m := NewOrderedMap[string, int]()
+	incr := func(old int, exists bool) (int, bool) {
+		return old + 1, true
+	}
+	m.Update("a", incr)
+	m.Update("a", incr)
+	m.Update("b", incr)
+	v, ok := m.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+
+	// Returning keep=false deletes the key, or inserts nothing.
+	m.Update("b", func(old int, exists bool) (int, bool) {
+		assert.True(t, exists)
+		assert.Equal(t, 1, old)
+		return 0, false
+	})
+	m.Update("c", func(old int, exists bool) (int, bool) {
+		assert.False(t, exists)
+		return 0, false
+	})
+	assert.Equal(t, 1, m.Len())
+	_, ok = m.Get("b")
+	assert.False(t, ok)
+	validateTree2(m.Tree())
+}
+
+func TestMapDeleteIf(t *testing.T) {
+	m := NewOrderedMap[string, int]()
+	for i, k := range []string{"a", "b", "c", "d", "e"} {
+		m.Set(k, i)
+	}
+	assert.Equal(t, 2, m.DeleteIf(func(key string, value int) bool { return value%2 == 1 }))
+	assert.Equal(t, 1, m.Retain(func(key string, value int) bool { return key != "c" }))
+	it := m.DeleteAndNext(m.Min())
+	assert.Equal(t, "e", it.Key())
+	it = m.DeleteAndPrev(it)
+	assert.True(t, it.NegativeLimit())
+	assert.Equal(t, 0, m.Len())
+}
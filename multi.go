@@ -0,0 +1,261 @@
+package rbtree
+
+import (
+	"cmp"
+	"iter"
+)
+
+// MultiTree is a red-black tree that may hold several elements that
+// compare equal. Equal elements are kept in insertion order: a new one
+// goes after those already in the tree.
+//
+// Iterators follow the same rules as Tree's.
+type MultiTree[T any] struct {
+	tree *Tree[T]
+}
+
+// Create a new empty MultiTree, see NewTree.
+func NewMultiTree[T any](compare func(a, b T) int) *MultiTree[T] {
+	t := NewTree(compare)
+	t.duplicates = true
+	return &MultiTree[T]{t}
+}
+
+// Create a new empty MultiTree of naturally ordered values, compared
+// with cmp.Compare.
+func NewOrderedMultiTree[T cmp.Ordered]() *MultiTree[T] {
+	return NewMultiTree(cmp.Compare[T])
+}
+
+// Return the number of elements in the tree, counting each occurrence.
+func (mt *MultiTree[T]) Len() int {
+	return mt.tree.count
+}
+
+// Insert item after any equal elements, and return an iterator
+// pointing to it.
+func (mt *MultiTree[T]) Insert(item T) Iterator[T] {
+	n := mt.tree.doInsert(item)
+	mt.tree.insertFixup(n)
+	return mt.tree.iterator(n)
+}
+
+// Create an iterator that points to the minimum item in the tree.
+// If the tree is empty, return Limit()
+func (mt *MultiTree[T]) Min() Iterator[T] {
+	return mt.tree.Min()
+}
+
+// Create an iterator that points at the maximum item in the tree.
+// If the tree is empty, return NegativeLimit()
+func (mt *MultiTree[T]) Max() Iterator[T] {
+	return mt.tree.Max()
+}
+
+// Create an iterator that points beyond the maximum item in the tree
+func (mt *MultiTree[T]) Limit() Iterator[T] {
+	return mt.tree.Limit()
+}
+
+// Create an iterator that points before the minimum item in the tree
+func (mt *MultiTree[T]) NegativeLimit() Iterator[T] {
+	return mt.tree.NegativeLimit()
+}
+
+// Find the first element N such that N >= key, and return the iterator
+// pointing to the element. If no such element is found, return
+// Limit().
+func (mt *MultiTree[T]) FindGE(key T) Iterator[T] {
+	return mt.tree.iterator(mt.tree.lowerBound(key))
+}
+
+// Find the last element N such that N <= key, and return the iterator
+// pointing to the element. If no such element is found, return
+// NegativeLimit().
+func (mt *MultiTree[T]) FindLE(key T) Iterator[T] {
+	if n := mt.tree.upperBound(key); n != nil {
+		return mt.tree.prevIterator(n)
+	}
+	return mt.tree.Max()
+}
+
+// Return the range [first, last) of the elements equal to key. If
+// there are none, first and last both point to the first element
+// greater than key, or to Limit().
+func (mt *MultiTree[T]) EqualRange(key T) (first, last Iterator[T]) {
+	return mt.tree.iterator(mt.tree.lowerBound(key)), mt.tree.iterator(mt.tree.upperBound(key))
+}
+
+// Return the number of elements equal to key, in O(log n) time.
+func (mt *MultiTree[T]) Count(key T) int {
+	return mt.tree.rankLE(key) - mt.tree.Rank(key)
+}
+
+// Delete the first element equal to key. Return true iff there was one.
+func (mt *MultiTree[T]) DeleteOne(key T) bool {
+	n := mt.tree.lowerBound(key)
+	if n == nil || mt.tree.compare(key, n.item) != 0 {
+		return false
+	}
+	mt.tree.doDelete(n)
+	return true
+}
+
+// Delete every element equal to key, and return how many there were.
+func (mt *MultiTree[T]) DeleteAll(key T) int {
+	deleted := 0
+	for mt.DeleteOne(key) {
+		deleted++
+	}
+	return deleted
+}
+
+// Delete the current item.
+//
+// REQUIRES: !iter.Limit() && !iter.NegativeLimit()
+func (mt *MultiTree[T]) DeleteWithIterator(iter Iterator[T]) {
+	mt.tree.DeleteWithIterator(iter)
+}
+
+// Return a sequence of the elements in order, see Tree.All.
+func (mt *MultiTree[T]) All() iter.Seq[T] {
+	return mt.tree.All()
+}
+
+// Return the first node N such that N >= key, or nil.
+func (root *Tree[T]) lowerBound(key T) *node[T] {
+	var ge *node[T]
+	for n := root.root; n != nil; {
+		if root.compare(key, n.item) <= 0 {
+			ge, n = n, n.left
+		} else {
+			n = n.right
+		}
+	}
+	return ge
+}
+
+// Return the first node N such that N > key, or nil.
+func (root *Tree[T]) upperBound(key T) *node[T] {
+	var gt *node[T]
+	for n := root.root; n != nil; {
+		if root.compare(key, n.item) < 0 {
+			gt, n = n, n.left
+		} else {
+			n = n.right
+		}
+	}
+	return gt
+}
+
+// Return the number of elements N such that N <= key.
+func (root *Tree[T]) rankLE(key T) int {
+	rank := 0
+	for n := root.root; n != nil; {
+		if root.compare(key, n.item) < 0 {
+			n = n.left
+		} else {
+			rank += getSize(n.left) + 1
+			n = n.right
+		}
+	}
+	return rank
+}
+
+// MultiMap like Map but a key may be mapped to several values, kept in
+// insertion order
+// implement by MultiTree
+type MultiMap[K, V any] struct {
+	tree *MultiTree[Pair[K, V]]
+}
+
+// NewMultiMap Create a new empty MultiMap, see NewMap
+func NewMultiMap[K, V any](compare func(a, b K) int) MultiMap[K, V] {
+	return MultiMap[K, V]{NewMultiTree(func(a, b Pair[K, V]) int {
+		return compare(a.key, b.key)
+	})}
+}
+
+// NewOrderedMultiMap Create a new empty MultiMap whose keys are compared
+// with cmp.Compare.
+func NewOrderedMultiMap[K cmp.Ordered, V any]() MultiMap[K, V] {
+	return NewMultiMap[K, V](cmp.Compare[K])
+}
+
+// follow MultiMap operation simple wrapper MultiTree
+
+func (m MultiMap[K, V]) Len() int {
+	return m.tree.Len()
+}
+
+// Insert add key -> value after the values already mapped from key
+func (m MultiMap[K, V]) Insert(key K, value V) MapIterator[K, V] {
+	return MapIterator[K, V]{m.tree.Insert(Pair[K, V]{key, value})}
+}
+
+func (m MultiMap[K, V]) Min() MapIterator[K, V] {
+	return MapIterator[K, V]{m.tree.Min()}
+}
+
+func (m MultiMap[K, V]) Max() MapIterator[K, V] {
+	return MapIterator[K, V]{m.tree.Max()}
+}
+
+func (m MultiMap[K, V]) Limit() MapIterator[K, V] {
+	return MapIterator[K, V]{m.tree.Limit()}
+}
+
+func (m MultiMap[K, V]) NegativeLimit() MapIterator[K, V] {
+	return MapIterator[K, V]{m.tree.NegativeLimit()}
+}
+
+func (m MultiMap[K, V]) FindGE(key K) MapIterator[K, V] {
+	return MapIterator[K, V]{m.tree.FindGE(Pair[K, V]{key: key})}
+}
+
+func (m MultiMap[K, V]) FindLE(key K) MapIterator[K, V] {
+	return MapIterator[K, V]{m.tree.FindLE(Pair[K, V]{key: key})}
+}
+
+// EqualRange return the range [first, last) of the pairs with key
+func (m MultiMap[K, V]) EqualRange(key K) (first, last MapIterator[K, V]) {
+	f, l := m.tree.EqualRange(Pair[K, V]{key: key})
+	return MapIterator[K, V]{f}, MapIterator[K, V]{l}
+}
+
+// Count return the number of values mapped from key
+func (m MultiMap[K, V]) Count(key K) int {
+	return m.tree.Count(Pair[K, V]{key: key})
+}
+
+// Values return a sequence of the values mapped from key, in insertion
+// order
+func (m MultiMap[K, V]) Values(key K) iter.Seq[V] {
+	return func(yield func(V) bool) {
+		first, last := m.EqualRange(key)
+		for it := first; !it.Equal(last) && yield(it.Value()); it = it.Next() {
+		}
+	}
+}
+
+// DeleteOne delete the first pair with key, return true if found
+func (m MultiMap[K, V]) DeleteOne(key K) bool {
+	return m.tree.DeleteOne(Pair[K, V]{key: key})
+}
+
+// DeleteAll delete every pair with key, return the number deleted
+func (m MultiMap[K, V]) DeleteAll(key K) int {
+	return m.tree.DeleteAll(Pair[K, V]{key: key})
+}
+
+func (m MultiMap[K, V]) DeleteWithIterator(iter MapIterator[K, V]) {
+	m.tree.DeleteWithIterator(iter.Iterator)
+}
+
+// All return a sequence of the key/value pairs in key order
+func (m MultiMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for n := m.tree.tree.minNode; n != nil && yield(n.item.key, n.item.value); n = n.doNext() {
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package rbtree
+
+import (
+	"cmp"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type seqItem struct{ key, seq int }
+
+func TestMultiTreeRandomized(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	tree := NewMultiTree(func(a, b seqItem) int { return cmp.Compare(a.key, b.key) })
+	var o []seqItem // sorted by key, then insertion order
+	for i := 0; i < 3000; i++ {
+		key := r.Intn(50)
+		switch r.Intn(4) {
+		case 0:
+			first := slices.IndexFunc(o, func(s seqItem) bool { return s.key == key })
+			assert.Equal(t, first >= 0, tree.DeleteOne(seqItem{key: key}))
+			if first >= 0 {
+				o = slices.Delete(o, first, first+1)
+			}
+		case 1:
+			if r.Intn(10) == 0 {
+				n := len(o)
+				o = slices.DeleteFunc(o, func(s seqItem) bool { return s.key == key })
+				assert.Equal(t, n-len(o), tree.DeleteAll(seqItem{key: key}))
+			}
+		default:
+			it := tree.Insert(seqItem{key, i})
+			assert.Equal(t, seqItem{key, i}, it.Item())
+			pos, _ := slices.BinarySearchFunc(o, key+1, func(s seqItem, k int) int { return cmp.Compare(s.key, k) })
+			o = slices.Insert(o, pos, seqItem{key, i})
+		}
+
+		if i%100 == 0 {
+			validateTree2(tree.tree)
+			assert.Equal(t, o, slices.Collect(tree.All()))
+			for k := -1; k <= 50; k++ {
+				lo, _ := slices.BinarySearchFunc(o, k, func(s seqItem, k int) int { return cmp.Compare(s.key, k) })
+				hi, _ := slices.BinarySearchFunc(o, k+1, func(s seqItem, k int) int { return cmp.Compare(s.key, k) })
+				assert.Equal(t, hi-lo, tree.Count(seqItem{key: k}))
+				first, last := tree.EqualRange(seqItem{key: k})
+				assert.Equal(t, lo, first.Index())
+				assert.Equal(t, hi, last.Index())
+				assert.Equal(t, lo, tree.FindGE(seqItem{key: k}).Index())
+				assert.Equal(t, hi-1, tree.FindLE(seqItem{key: k}).Index())
+			}
+		}
+	}
+}
+
+func TestMultiMap(t *testing.T) {
+	m := NewOrderedMultiMap[string, int]()
+	m.Insert("b", 1)
+	m.Insert("a", 2)
+	m.Insert("b", 3)
+	m.Insert("b", 4)
+	assert.Equal(t, 4, m.Len())
+	assert.Equal(t, 3, m.Count("b"))
+	assert.Equal(t, 0, m.Count("c"))
+	assert.Equal(t, []int{1, 3, 4}, slices.Collect(m.Values("b")))
+
+	first, last := m.EqualRange("b")
+	assert.Equal(t, 1, first.Value())
+	assert.True(t, last.Limit())
+	first, last = m.EqualRange("aa")
+	assert.True(t, first.Equal(last))
+	assert.Equal(t, "b", first.Key())
+
+	assert.True(t, m.DeleteOne("b"))
+	assert.Equal(t, []int{3, 4}, slices.Collect(m.Values("b")))
+	m.DeleteWithIterator(m.FindLE("b"))
+	assert.Equal(t, []int{3}, slices.Collect(m.Values("b")))
+	assert.Equal(t, 1, m.DeleteAll("b"))
+	assert.False(t, m.DeleteOne("b"))
+
+	keys := []string{}
+	for k := range m.All() {
+		keys = append(keys, k)
+	}
+	assert.Equal(t, []string{"a"}, keys)
+	assert.Equal(t, "a", m.Min().Key())
+	assert.Equal(t, "a", m.Max().Key())
+}
@@ -0,0 +1,138 @@
+package rbtree
+
+import "iter"
+
+// Ordered is a set of T values kept in the order of a comparison
+// function. Tree implements it, as do CompactTree and the alternative
+// backends AVLTree, BTree and SkipList, so that code written against
+// Ordered can switch backends by calling a different constructor:
+//
+//	var set rbtree.Ordered[int] = rbtree.NewOrderedBTree[int]()
+//
+// None of the implementations is safe for concurrent use. The
+// sequences walk the set when they are ranged over, and the set must
+// not be modified meanwhile.
+type Ordered[T any] interface {
+	// Return the number of elements.
+	Len() int
+	// Insert item. If an equal element is already in the set, do
+	// nothing and return false. Else return true.
+	Insert(item T) bool
+	// Delete the element equal to key. Return true iff there was one.
+	DeleteWithKey(key T) bool
+	// Return the smallest element N such that N >= key, if any.
+	GetGE(key T) (T, bool)
+	// Return the largest element N such that N <= key, if any.
+	GetLE(key T) (T, bool)
+	// Return the minimum element, if any.
+	GetMin() (T, bool)
+	// Return the maximum element, if any.
+	GetMax() (T, bool)
+	// Return a sequence of the elements in ascending order.
+	All() iter.Seq[T]
+	// Return a sequence of the elements in descending order.
+	Backward() iter.Seq[T]
+	// Return a sequence of the elements N >= key in ascending order.
+	AscendGE(key T) iter.Seq[T]
+	// Return a sequence of the elements N <= key in descending order.
+	DescendLE(key T) iter.Seq[T]
+}
+
+var (
+	_ Ordered[int] = (*Tree[int])(nil)
+	_ Ordered[int] = (*CompactTree[int])(nil)
+	_ Ordered[int] = (*AVLTree[int])(nil)
+	_ Ordered[int] = (*BTree[int])(nil)
+	_ Ordered[int] = (*SkipList[int])(nil)
+)
+
+// Return the smallest element N such that N >= key, if any.
+func (root *Tree[T]) GetGE(key T) (T, bool) {
+	n, _ := root.findGE(key)
+	if n == nil {
+		var zero T
+		return zero, false
+	}
+	return n.item, true
+}
+
+// Return the largest element N such that N <= key, if any.
+func (root *Tree[T]) GetLE(key T) (T, bool) {
+	iter := root.FindLE(key)
+	if iter.NegativeLimit() {
+		var zero T
+		return zero, false
+	}
+	return iter.node.item, true
+}
+
+// Return the minimum element, if any.
+func (root *Tree[T]) GetMin() (T, bool) {
+	if root.minNode == nil {
+		var zero T
+		return zero, false
+	}
+	return root.minNode.item, true
+}
+
+// Return the maximum element, if any.
+func (root *Tree[T]) GetMax() (T, bool) {
+	if root.maxNode == nil {
+		var zero T
+		return zero, false
+	}
+	return root.maxNode.item, true
+}
+
+// Return a sequence of the elements N >= key in ascending order.
+func (root *Tree[T]) AscendGE(key T) iter.Seq[T] {
+	return root.Between(key, key, RangeOptions{HiUnbounded: true})
+}
+
+// Return a sequence of the elements N <= key in descending order.
+func (root *Tree[T]) DescendLE(key T) iter.Seq[T] {
+	return root.Between(key, key, RangeOptions{Bounds: Closed, LoUnbounded: true, Descending: true})
+}
+
+// Return the smallest element N such that N >= key, if any.
+func (t *CompactTree[T]) GetGE(key T) (T, bool) {
+	return t.FindGE(key).get()
+}
+
+// Return the largest element N such that N <= key, if any.
+func (t *CompactTree[T]) GetLE(key T) (T, bool) {
+	return t.FindLE(key).get()
+}
+
+// Return the minimum element, if any.
+func (t *CompactTree[T]) GetMin() (T, bool) {
+	return t.Min().get()
+}
+
+// Return the maximum element, if any.
+func (t *CompactTree[T]) GetMax() (T, bool) {
+	return t.Max().get()
+}
+
+// Return a sequence of the elements N >= key in ascending order.
+func (t *CompactTree[T]) AscendGE(key T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		t.Range(key, key, RangeOptions{HiUnbounded: true}, yield)
+	}
+}
+
+// Return a sequence of the elements N <= key in descending order.
+func (t *CompactTree[T]) DescendLE(key T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		t.Range(key, key, RangeOptions{Bounds: Closed, LoUnbounded: true, Descending: true}, yield)
+	}
+}
+
+// Return the current element, unless the iterator is at either limit.
+func (iter CompactIterator[T]) get() (T, bool) {
+	if iter.Limit() || iter.NegativeLimit() {
+		var zero T
+		return zero, false
+	}
+	return iter.tree.at(iter.node).item, true
+}
@@ -0,0 +1,180 @@
+package rbtree
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var orderedBackends = []struct {
+	name string
+	new  func() Ordered[int]
+}{
+	{"Tree", func() Ordered[int] { return NewOrderedTree[int]() }},
+	{"CompactTree", func() Ordered[int] { return NewOrderedCompactTree[int]() }},
+	{"AVLTree", func() Ordered[int] { return NewOrderedAVLTree[int]() }},
+	{"BTree", func() Ordered[int] { return NewOrderedBTree[int]() }},
+	{"SkipList", func() Ordered[int] { return NewOrderedSkipList[int]() }},
+}
+
+// Check every read operation of set against want, its sorted contents.
+func checkOrdered(t *testing.T, set Ordered[int], want []int, r *rand.Rand) {
+	assert.Equal(t, len(want), set.Len())
+	if len(want) == 0 {
+		want = nil
+	}
+	assert.Equal(t, want, slices.Collect(set.All()))
+	backward := slices.Clone(want)
+	slices.Reverse(backward)
+	assert.Equal(t, backward, slices.Collect(set.Backward()))
+
+	item, ok := set.GetMin()
+	assert.Equal(t, len(want) > 0, ok)
+	if ok {
+		assert.Equal(t, want[0], item)
+	}
+	item, ok = set.GetMax()
+	assert.Equal(t, len(want) > 0, ok)
+	if ok {
+		assert.Equal(t, want[len(want)-1], item)
+	}
+
+	for range 20 {
+		key := r.Intn(2100) - 50
+		i, found := slices.BinarySearch(want, key)
+		item, ok := set.GetGE(key)
+		assert.Equal(t, i < len(want), ok)
+		if ok {
+			assert.Equal(t, want[i], item)
+		}
+		j := i - 1
+		if found {
+			j = i
+		}
+		item, ok = set.GetLE(key)
+		assert.Equal(t, j >= 0, ok)
+		if ok {
+			assert.Equal(t, want[j], item)
+		}
+
+		ge := slices.Collect(set.AscendGE(key))
+		assert.Equal(t, len(want)-i, len(ge))
+		if len(ge) > 0 {
+			assert.Equal(t, want[i:], ge)
+		}
+		le := slices.Collect(set.DescendLE(key))
+		assert.Equal(t, j+1, len(le))
+		if len(le) > 0 {
+			assert.Equal(t, backward[len(want)-j-1:], le)
+		}
+	}
+
+	// Stopping early.
+	if len(want) > 2 {
+		var two []int
+		for item := range set.All() {
+			two = append(two, item)
+			if len(two) == 2 {
+				break
+			}
+		}
+		assert.Equal(t, want[:2], two)
+	}
+}
+
+func TestOrderedBackends(t *testing.T) {
+	for _, backend := range orderedBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			set := backend.new()
+			r := rand.New(rand.NewSource(0))
+			o := map[int]bool{}
+			checkOrdered(t, set, nil, r)
+			for round := 0; round < 20; round++ {
+				for i := 0; i < 1000; i++ {
+					key := r.Intn(2000)
+					// Grow the set over the first rounds, then shrink it.
+					if r.Intn(20) < 6+round {
+						assert.Equal(t, o[key], set.DeleteWithKey(key))
+						delete(o, key)
+					} else {
+						assert.Equal(t, !o[key], set.Insert(key))
+						o[key] = true
+					}
+				}
+				want := make([]int, 0, len(o))
+				for k := range o {
+					want = append(want, k)
+				}
+				slices.Sort(want)
+				checkOrdered(t, set, want, r)
+			}
+		})
+	}
+}
+
+// Benchmarks shared by the backends of Ordered, run with
+// go test -bench Ordered.
+
+const benchmarkOrderedSize = 100000
+
+// Return a set of benchmarkOrderedSize random even numbers.
+func newBenchmarkSet(new func() Ordered[int]) Ordered[int] {
+	set := new()
+	r := rand.New(rand.NewSource(0))
+	for set.Len() < benchmarkOrderedSize {
+		set.Insert(2 * r.Intn(4*benchmarkOrderedSize))
+	}
+	return set
+}
+
+func BenchmarkOrdered(b *testing.B) {
+	for _, backend := range orderedBackends {
+		b.Run(fmt.Sprintf("Insert/%s", backend.name), func(b *testing.B) {
+			b.ReportAllocs()
+			r := rand.New(rand.NewSource(0))
+			set := backend.new()
+			for i := 0; i < b.N; i++ {
+				if set.Len() == benchmarkOrderedSize {
+					b.StopTimer()
+					set = backend.new()
+					b.StartTimer()
+				}
+				set.Insert(r.Int())
+			}
+		})
+		b.Run(fmt.Sprintf("GetGE/%s", backend.name), func(b *testing.B) {
+			set := newBenchmarkSet(backend.new)
+			r := rand.New(rand.NewSource(1))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				set.GetGE(r.Intn(8 * benchmarkOrderedSize))
+			}
+		})
+		b.Run(fmt.Sprintf("Churn/%s", backend.name), func(b *testing.B) {
+			set := newBenchmarkSet(backend.new)
+			r := rand.New(rand.NewSource(1))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// Replace an element by an odd number, or back.
+				if item, ok := set.GetGE(r.Intn(8 * benchmarkOrderedSize)); ok {
+					set.DeleteWithKey(item)
+					set.Insert(item ^ 1)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("Scan/%s", backend.name), func(b *testing.B) {
+			set := newBenchmarkSet(backend.new)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for range set.All() {
+				}
+			}
+		})
+	}
+}
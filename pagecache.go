@@ -0,0 +1,90 @@
+package rbtree
+
+import (
+	"container/list"
+	"errors"
+	"io"
+	"os"
+)
+
+// A page of a file held in a pageCache.
+type cachedPage struct {
+	num   uint32
+	data  []byte
+	dirty bool
+}
+
+// pageCache keeps up to capacity fixed-size pages of a file in memory,
+// evicting the least recently used page when full. Dirty pages are
+// written back when evicted or flushed.
+type pageCache struct {
+	file     *os.File
+	pageSize int
+	capacity int
+	lru      *list.List // of *cachedPage, most recently used first
+	pages    map[uint32]*list.Element
+}
+
+func newPageCache(file *os.File, pageSize, capacity int) *pageCache {
+	return &pageCache{
+		file:     file,
+		pageSize: pageSize,
+		capacity: max(capacity, 1),
+		lru:      list.New(),
+		pages:    make(map[uint32]*list.Element),
+	}
+}
+
+// Return the contents of page num, reading it if needed. Pages past the
+// end of the file read as zeros. If write is true, the page is marked
+// dirty. The slice is only valid until the next call to get.
+func (c *pageCache) get(num uint32, write bool) ([]byte, error) {
+	if e, ok := c.pages[num]; ok {
+		c.lru.MoveToFront(e)
+		p := e.Value.(*cachedPage)
+		p.dirty = p.dirty || write
+		return p.data, nil
+	}
+	var p *cachedPage
+	if c.lru.Len() >= c.capacity {
+		// Reuse the buffer of the victim.
+		e := c.lru.Back()
+		p = e.Value.(*cachedPage)
+		if err := c.writeBack(p); err != nil {
+			return nil, err
+		}
+		c.lru.Remove(e)
+		delete(c.pages, p.num)
+	} else {
+		p = &cachedPage{data: make([]byte, c.pageSize)}
+	}
+	n, err := c.file.ReadAt(p.data, int64(num)*int64(c.pageSize))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	clear(p.data[n:])
+	p.num, p.dirty = num, write
+	c.pages[num] = c.lru.PushFront(p)
+	return p.data, nil
+}
+
+func (c *pageCache) writeBack(p *cachedPage) error {
+	if !p.dirty {
+		return nil
+	}
+	if _, err := c.file.WriteAt(p.data, int64(p.num)*int64(c.pageSize)); err != nil {
+		return err
+	}
+	p.dirty = false
+	return nil
+}
+
+// Write all dirty pages back to the file.
+func (c *pageCache) flush() error {
+	for e := c.lru.Front(); e != nil; e = e.Next() {
+		if err := c.writeBack(e.Value.(*cachedPage)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
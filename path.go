@@ -0,0 +1,108 @@
+package rbtree
+
+// pathNode is a tree node whose parent cannot be reached from the node
+// itself, either because it has no parent pointer (pnode) or because
+// the node may be shared by several trees (a snapshotted node). Such
+// nodes are iterated by keeping the path from the root.
+//
+// All path slices below have a capacity equal to their length, so that
+// extending a path copies it instead of overwriting an array that
+// another iterator still uses.
+type pathNode[N any] interface {
+	comparable
+	children() (left, right N)
+}
+
+func (n *pnode[T]) children() (left, right *pnode[T]) {
+	return n.left, n.right
+}
+
+func (n *node[T]) children() (left, right *node[T]) {
+	return n.left, n.right
+}
+
+func trimPath[N any](path []N) []N {
+	return path[:len(path):len(path)]
+}
+
+// Extend path with the nodes from n down to the minimum of n's subtree.
+func appendMinPath[N pathNode[N]](path []N, n N) []N {
+	var nilNode N
+	for n != nilNode {
+		path = append(path, n)
+		n, _ = n.children()
+	}
+	return trimPath(path)
+}
+
+// Extend path with the nodes from n down to the maximum of n's subtree.
+func appendMaxPath[N pathNode[N]](path []N, n N) []N {
+	var nilNode N
+	for n != nilNode {
+		path = append(path, n)
+		_, n = n.children()
+	}
+	return trimPath(path)
+}
+
+// Return the path to the successor of the last node of path. The
+// result is empty if there is none.
+func nextPath[N pathNode[N]](path []N) []N {
+	var nilNode N
+	if _, right := path[len(path)-1].children(); right != nilNode {
+		return appendMinPath(path, right)
+	}
+	for len(path) > 1 {
+		if _, right := path[len(path)-2].children(); right != path[len(path)-1] {
+			break
+		}
+		path = path[:len(path)-1]
+	}
+	return trimPath(path[:len(path)-1])
+}
+
+// Return the path to the predecessor of the last node of path. The
+// result is empty if there is none.
+func prevPath[N pathNode[N]](path []N) []N {
+	var nilNode N
+	if left, _ := path[len(path)-1].children(); left != nilNode {
+		return appendMaxPath(path, left)
+	}
+	for len(path) > 1 {
+		if left, _ := path[len(path)-2].children(); left != path[len(path)-1] {
+			break
+		}
+		path = path[:len(path)-1]
+	}
+	return trimPath(path[:len(path)-1])
+}
+
+// Return the path to the smallest node N with compareKey(N) <= 0 (that
+// is, key <= N) if ge, or else to the largest node N with
+// compareKey(N) >= 0. The result is empty if there is none.
+func findPath[N pathNode[N]](root N, compareKey func(N) int, ge bool) []N {
+	var nilNode N
+	var path []N
+	found := 0
+	for n := root; n != nilNode; {
+		path = append(path, n)
+		c := compareKey(n)
+		if c == 0 {
+			found = len(path)
+			break
+		}
+		left, right := n.children()
+		if c < 0 {
+			if ge {
+				found = len(path)
+			}
+			n = left
+		} else {
+			if !ge {
+				found = len(path)
+			}
+			n = right
+		}
+	}
+	return path[:found:found]
+}
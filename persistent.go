@@ -0,0 +1,353 @@
+package rbtree
+
+import "cmp"
+
+// PersistentTree is an immutable red-black tree. Insert and Delete
+// leave the receiver untouched and return a new tree that shares every
+// node off the modified path with it, so a *PersistentTree can be
+// handed to readers as a snapshot that never observes later updates.
+//
+// Nodes carry no parent pointers; PersistentIterator instead keeps the
+// path from the root. Insertion follows Okasaki and deletion follows
+// Kahrs, "Red-black trees with types" (JFP 2001).
+type PersistentTree[T any] struct {
+	root    *pnode[T]
+	count   int
+	compare func(a, b T) int
+}
+
+type pnode[T any] struct {
+	item        T
+	left, right *pnode[T]
+	color       int // black or red
+}
+
+// Create a new empty persistent tree. compare returns 0 if a==b, <0 if
+// a<b, >0 if a>b.
+func NewPersistentTree[T any](compare func(a, b T) int) *PersistentTree[T] {
+	return &PersistentTree[T]{compare: compare}
+}
+
+// Create a new empty persistent tree of naturally ordered values.
+func NewOrderedPersistentTree[T cmp.Ordered]() *PersistentTree[T] {
+	return NewPersistentTree(cmp.Compare[T])
+}
+
+// Return the number of elements in the tree.
+func (t *PersistentTree[T]) Len() int {
+	return t.count
+}
+
+// A convenience function for finding an element equal to key. Return
+// the zero value of T if not found.
+func (t *PersistentTree[T]) Get(key T) T {
+	if n := t.find(key); n != nil {
+		return n.item
+	}
+	var zero T
+	return zero
+}
+
+// Return a tree that also contains item. If an equal item is already
+// in the tree, return the receiver itself and false.
+func (t *PersistentTree[T]) Insert(item T) (*PersistentTree[T], bool) {
+	root, inserted := t.insert(t.root, item)
+	if !inserted {
+		return t, false
+	}
+	// The root returned by a successful insert is always a fresh copy.
+	root.color = black
+	return &PersistentTree[T]{root, t.count + 1, t.compare}, true
+}
+
+// Return a tree without the element equal to key. If there is no such
+// element, return the receiver itself and false.
+func (t *PersistentTree[T]) Delete(key T) (*PersistentTree[T], bool) {
+	if t.find(key) == nil {
+		return t, false
+	}
+	root := t.delete(t.root, key)
+	if root != nil && root.color == red {
+		root = newPNode(black, root.left, root.item, root.right)
+	}
+	return &PersistentTree[T]{root, t.count - 1, t.compare}, true
+}
+
+// Create an iterator that points to the minimum item in the tree. If
+// the tree is empty, return Limit().
+func (t *PersistentTree[T]) Min() PersistentIterator[T] {
+	return PersistentIterator[T]{tree: t, path: appendMinPath(nil, t.root)}
+}
+
+// Create an iterator that points at the maximum item in the tree. If
+// the tree is empty, return NegativeLimit().
+func (t *PersistentTree[T]) Max() PersistentIterator[T] {
+	if t.root == nil {
+		return t.NegativeLimit()
+	}
+	return PersistentIterator[T]{tree: t, path: appendMaxPath(nil, t.root)}
+}
+
+// Create an iterator that points beyond the maximum item in the tree.
+func (t *PersistentTree[T]) Limit() PersistentIterator[T] {
+	return PersistentIterator[T]{tree: t}
+}
+
+// Create an iterator that points before the minimum item in the tree.
+func (t *PersistentTree[T]) NegativeLimit() PersistentIterator[T] {
+	return PersistentIterator[T]{tree: t, negativeLimit: true}
+}
+
+// Find the smallest element N such that N >= key, and return the
+// iterator pointing to the element. If no such element is found,
+// return Limit().
+func (t *PersistentTree[T]) FindGE(key T) PersistentIterator[T] {
+	path := findPath(t.root, func(n *pnode[T]) int { return t.compare(key, n.item) }, true)
+	return PersistentIterator[T]{tree: t, path: path}
+}
+
+// Find the largest element N such that N <= key, and return the
+// iterator pointing to the element. If no such element is found,
+// return NegativeLimit().
+func (t *PersistentTree[T]) FindLE(key T) PersistentIterator[T] {
+	path := findPath(t.root, func(n *pnode[T]) int { return t.compare(key, n.item) }, false)
+	if len(path) == 0 {
+		return t.NegativeLimit()
+	}
+	return PersistentIterator[T]{tree: t, path: path}
+}
+
+// PersistentIterator allows scanning a PersistentTree in sort order.
+// Since the tree never changes, an iterator stays valid forever.
+type PersistentIterator[T any] struct {
+	tree *PersistentTree[T]
+
+	// Path from the root to the current node. Empty at either limit.
+	path          []*pnode[T]
+	negativeLimit bool
+}
+
+func (iter PersistentIterator[T]) Equal(iter2 PersistentIterator[T]) bool {
+	return iter.negativeLimit == iter2.negativeLimit && iter.current() == iter2.current()
+}
+
+// Check if the iterator points beyond the max element in the tree
+func (iter PersistentIterator[T]) Limit() bool {
+	return len(iter.path) == 0 && !iter.negativeLimit
+}
+
+// Check if the iterator points before the minumum element in the tree
+func (iter PersistentIterator[T]) NegativeLimit() bool {
+	return iter.negativeLimit
+}
+
+// Return the current element.
+//
+// REQUIRES: !iter.Limit() && !iter.NegativeLimit()
+func (iter PersistentIterator[T]) Item() T {
+	return iter.current().item
+}
+
+// Create a new iterator that points to the successor of the current element.
+//
+// REQUIRES: !iter.Limit()
+func (iter PersistentIterator[T]) Next() PersistentIterator[T] {
+	doAssert(!iter.Limit())
+	if iter.negativeLimit {
+		return iter.tree.Min()
+	}
+	return PersistentIterator[T]{tree: iter.tree, path: nextPath(iter.path)}
+}
+
+// Create a new iterator that points to the predecessor of the current
+// node.
+//
+// REQUIRES: !iter.NegativeLimit()
+func (iter PersistentIterator[T]) Prev() PersistentIterator[T] {
+	doAssert(!iter.NegativeLimit())
+	if iter.Limit() {
+		return iter.tree.Max()
+	}
+	path := prevPath(iter.path)
+	if len(path) == 0 {
+		return iter.tree.NegativeLimit()
+	}
+	return PersistentIterator[T]{tree: iter.tree, path: path}
+}
+
+// Return the node equal to key, or nil.
+func (t *PersistentTree[T]) find(key T) *pnode[T] {
+	n := t.root
+	for n != nil {
+		c := t.compare(key, n.item)
+		if c == 0 {
+			return n
+		} else if c < 0 {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	return nil
+}
+
+func (iter PersistentIterator[T]) current() *pnode[T] {
+	if len(iter.path) == 0 {
+		return nil
+	}
+	return iter.path[len(iter.path)-1]
+}
+
+//
+// Path-copying insert and delete. Every function below returns new
+// nodes and never modifies an existing one.
+//
+
+func newPNode[T any](color int, left *pnode[T], item T, right *pnode[T]) *pnode[T] {
+	return &pnode[T]{item: item, left: left, right: right, color: color}
+}
+
+func isRed[T any](n *pnode[T]) bool {
+	return n != nil && n.color == red
+}
+
+func isBlack[T any](n *pnode[T]) bool {
+	return n != nil && n.color == black
+}
+
+func (t *PersistentTree[T]) insert(n *pnode[T], item T) (*pnode[T], bool) {
+	if n == nil {
+		return newPNode(red, nil, item, nil), true
+	}
+	c := t.compare(item, n.item)
+	if c < 0 {
+		left, inserted := t.insert(n.left, item)
+		if !inserted {
+			return n, false
+		}
+		if n.color == black {
+			return balance(left, n.item, n.right), true
+		}
+		return newPNode(red, left, n.item, n.right), true
+	} else if c > 0 {
+		right, inserted := t.insert(n.right, item)
+		if !inserted {
+			return n, false
+		}
+		if n.color == black {
+			return balance(n.left, n.item, right), true
+		}
+		return newPNode(red, n.left, n.item, right), true
+	}
+	return n, false
+}
+
+// REQUIRES: key is in the subtree n
+func (t *PersistentTree[T]) delete(n *pnode[T], key T) *pnode[T] {
+	c := t.compare(key, n.item)
+	if c < 0 {
+		if isBlack(n.left) {
+			return balanceLeft(t.delete(n.left, key), n.item, n.right)
+		}
+		return newPNode(red, t.delete(n.left, key), n.item, n.right)
+	} else if c > 0 {
+		if isBlack(n.right) {
+			return balanceRight(n.left, n.item, t.delete(n.right, key))
+		}
+		return newPNode(red, n.left, n.item, t.delete(n.right, key))
+	}
+	return fuse(n.left, n.right)
+}
+
+// Build a black node from left, item and right, repairing a red node
+// with a red child on either side.
+func balance[T any](left *pnode[T], item T, right *pnode[T]) *pnode[T] {
+	switch {
+	case isRed(left) && isRed(right):
+		return newPNode(red,
+			newPNode(black, left.left, left.item, left.right), item,
+			newPNode(black, right.left, right.item, right.right))
+	case isRed(left) && isRed(left.left):
+		return newPNode(red,
+			newPNode(black, left.left.left, left.left.item, left.left.right), left.item,
+			newPNode(black, left.right, item, right))
+	case isRed(left) && isRed(left.right):
+		return newPNode(red,
+			newPNode(black, left.left, left.item, left.right.left), left.right.item,
+			newPNode(black, left.right.right, item, right))
+	case isRed(right) && isRed(right.right):
+		return newPNode(red,
+			newPNode(black, left, item, right.left), right.item,
+			newPNode(black, right.right.left, right.right.item, right.right.right))
+	case isRed(right) && isRed(right.left):
+		return newPNode(red,
+			newPNode(black, left, item, right.left.left), right.left.item,
+			newPNode(black, right.left.right, right.item, right.right))
+	}
+	return newPNode(black, left, item, right)
+}
+
+// Rebuild a node whose left subtree has lost one unit of black height.
+func balanceLeft[T any](left *pnode[T], item T, right *pnode[T]) *pnode[T] {
+	switch {
+	case isRed(left):
+		return newPNode(red, newPNode(black, left.left, left.item, left.right), item, right)
+	case isBlack(right):
+		return balance(left, item, newPNode(red, right.left, right.item, right.right))
+	case isRed(right) && isBlack(right.left):
+		return newPNode(red,
+			newPNode(black, left, item, right.left.left), right.left.item,
+			balance(right.left.right, right.item, redden(right.right)))
+	}
+	panic("rbtree internal assertion failed: balanceLeft")
+}
+
+// Rebuild a node whose right subtree has lost one unit of black height.
+func balanceRight[T any](left *pnode[T], item T, right *pnode[T]) *pnode[T] {
+	switch {
+	case isRed(right):
+		return newPNode(red, left, item, newPNode(black, right.left, right.item, right.right))
+	case isBlack(left):
+		return balance(newPNode(red, left.left, left.item, left.right), item, right)
+	case isRed(left) && isBlack(left.right):
+		return newPNode(red,
+			balance(redden(left.left), left.item, left.right.left), left.right.item,
+			newPNode(black, left.right.right, item, right))
+	}
+	panic("rbtree internal assertion failed: balanceRight")
+}
+
+func redden[T any](n *pnode[T]) *pnode[T] {
+	doAssert(isBlack(n))
+	return newPNode(red, n.left, n.item, n.right)
+}
+
+// Join two subtrees of equal black height, all of whose elements in
+// left are smaller than those in right.
+func fuse[T any](left, right *pnode[T]) *pnode[T] {
+	switch {
+	case left == nil:
+		return right
+	case right == nil:
+		return left
+	case isRed(left) && isRed(right):
+		m := fuse(left.right, right.left)
+		if isRed(m) {
+			return newPNode(red,
+				newPNode(red, left.left, left.item, m.left), m.item,
+				newPNode(red, m.right, right.item, right.right))
+		}
+		return newPNode(red, left.left, left.item, newPNode(red, m, right.item, right.right))
+	case isBlack(left) && isBlack(right):
+		m := fuse(left.right, right.left)
+		if isRed(m) {
+			return newPNode(red,
+				newPNode(black, left.left, left.item, m.left), m.item,
+				newPNode(black, m.right, right.item, right.right))
+		}
+		return balanceLeft(left.left, left.item, newPNode(black, m, right.item, right.right))
+	case isRed(right):
+		return newPNode(red, fuse(left, right.left), right.item, right.right)
+	}
+	return newPNode(red, left.left, left.item, fuse(left.right, right))
+}
@@ -0,0 +1,124 @@
+package rbtree
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Check the red-black invariants of a persistent tree and return its
+// black height.
+func validatePersistent[T any](t *testing.T, tree *PersistentTree[T], n *pnode[T]) int {
+	if n == nil {
+		return 1
+	}
+	if isRed(n) {
+		assert.False(t, isRed(n.left) || isRed(n.right), "red node with a red child")
+	}
+	if n.left != nil {
+		assert.Less(t, tree.compare(n.left.item, n.item), 0, "left child out of order")
+	}
+	if n.right != nil {
+		assert.Greater(t, tree.compare(n.right.item, n.item), 0, "right child out of order")
+	}
+	lh := validatePersistent(t, tree, n.left)
+	rh := validatePersistent(t, tree, n.right)
+	assert.Equal(t, lh, rh, "unequal black heights")
+	if n.color == black {
+		return lh + 1
+	}
+	return lh
+}
+
+func persistentToSlice(tree *PersistentTree[int]) []int {
+	out := []int{}
+	for it := tree.Min(); !it.Limit(); it = it.Next() {
+		out = append(out, it.Item())
+	}
+	return out
+}
+
+func persistentToReverseSlice(tree *PersistentTree[int]) []int {
+	out := []int{}
+	for it := tree.Max(); !it.NegativeLimit(); it = it.Prev() {
+		out = append([]int{it.Item()}, out...)
+	}
+	return out
+}
+
+func TestPersistentRandomized(t *testing.T) {
+	o := newOracle()
+	tree := NewOrderedPersistentTree[int]()
+	r := rand.New(rand.NewSource(0))
+
+	type version struct {
+		tree *PersistentTree[int]
+		data []int
+	}
+	var versions []version
+	for i := 0; i < 3000; i++ {
+		var changed bool
+		if r.Intn(3) > 0 || o.Len() == 0 {
+			key := r.Intn(500)
+			tree, changed = tree.Insert(key)
+			assert.Equal(t, o.Insert(key), changed)
+		} else {
+			key := o.RandomExistingKey(r)
+			o.Delete(key)
+			tree, changed = tree.Delete(key)
+			assert.True(t, changed)
+		}
+		if i%100 == 0 {
+			validatePersistent(t, tree, tree.root)
+			assert.Equal(t, o.Len(), tree.Len())
+			versions = append(versions, version{tree, append([]int{}, o.data...)})
+		}
+	}
+
+	// Every version still holds exactly the data it held when taken.
+	for _, v := range versions {
+		validatePersistent(t, v.tree, v.tree.root)
+		assert.Equal(t, v.data, persistentToSlice(v.tree))
+		assert.Equal(t, v.data, persistentToReverseSlice(v.tree))
+	}
+}
+
+func TestPersistentFind(t *testing.T) {
+	tree := NewOrderedPersistentTree[int]()
+	for i := 0; i < 10; i += 2 {
+		tree, _ = tree.Insert(i)
+	}
+	assert.Equal(t, 4, tree.FindGE(3).Item())
+	assert.Equal(t, 4, tree.FindGE(4).Item())
+	assert.True(t, tree.FindGE(9).Limit())
+	assert.Equal(t, 2, tree.FindLE(3).Item())
+	assert.True(t, tree.FindLE(-1).NegativeLimit())
+	assert.Equal(t, 6, tree.FindLE(6).Next().Prev().Item())
+	assert.Equal(t, 0, tree.NegativeLimit().Next().Item())
+	assert.Equal(t, 8, tree.Limit().Prev().Item())
+	assert.True(t, tree.FindGE(3).Equal(tree.FindLE(4)))
+	assert.Equal(t, 6, tree.Get(6))
+	assert.Equal(t, 0, tree.Get(7))
+
+	same, deleted := tree.Delete(7)
+	assert.False(t, deleted)
+	assert.Same(t, tree, same)
+	same, inserted := tree.Insert(4)
+	assert.False(t, inserted)
+	assert.Same(t, tree, same)
+}
+
+func TestPersistentIteratorsDoNotShareState(t *testing.T) {
+	tree := NewOrderedPersistentTree[int]()
+	for i := 0; i < 100; i++ {
+		tree, _ = tree.Insert(i)
+	}
+	base := tree.FindGE(50)
+	up := base.Next().Next()
+	down := base.Prev()
+	assert.Equal(t, 50, base.Item())
+	assert.Equal(t, 52, up.Item())
+	assert.Equal(t, 49, down.Item())
+	assert.Equal(t, 51, base.Next().Item())
+}
@@ -0,0 +1,128 @@
+package rbtree
+
+// Number of nodes allocated at once by a nodePool.
+const poolSlabSize = 64
+
+// nodePool hands out nodes for one tree, taking them from the nodes
+// the tree deleted when there are any, and otherwise from a slab of
+// nodes allocated together.
+type nodePool[T any] struct {
+	free    *node[T] // deleted nodes, linked through right
+	augment augmenter[T]
+	// Return a node taken from the slab, which no iterator refers to.
+	fresh func() *node[T]
+}
+
+// Create a pool of nodes for a tree augmented by aug, which may be nil.
+func newNodePool[T any](aug augmenter[T]) *nodePool[T] {
+	p := &nodePool[T]{augment: aug}
+	if aug != nil {
+		p.fresh = aug.slabAllocator()
+	} else {
+		p.fresh = slabAllocator(func(n *node[T]) *node[T] { return n })
+	}
+	return p
+}
+
+// Return a function that hands out zeroed values of type N, seen as
+// nodes through get, allocating poolSlabSize of them at a time.
+func slabAllocator[N, T any](get func(n *N) *node[T]) func() *node[T] {
+	var slab []N
+	return func() *node[T] {
+		if len(slab) == 0 {
+			slab = make([]N, poolSlabSize)
+		}
+		n := get(&slab[0])
+		slab = slab[1:]
+		return n
+	}
+}
+
+// Return a zeroed node, except for its reuse count.
+func (p *nodePool[T]) get() *node[T] {
+	if n := p.free; n != nil {
+		p.free = n.right
+		n.right = nil
+		return n
+	}
+	return p.fresh()
+}
+
+// Add n, a node just deleted from the tree, to the free list. n must
+// not be shared with a snapshot, which own guarantees for any node the
+// tree deletes.
+func (p *nodePool[T]) put(n *node[T]) {
+	// Keep gen, so that the node still reads as deleted while it is in
+	// the pool, and bump reuse, so that iterators to it stay invalid
+	// once it is handed out again.
+	*n = node[T]{right: p.free, reuse: n.reuse + 1, gen: n.gen}
+	if p.augment != nil {
+		p.augment.clearAgg(n)
+	}
+	p.free = n
+}
+
+// Enable or disable the recycling of deleted nodes, which is off by
+// default. When enabled, the tree keeps the nodes it deletes and uses
+// them for the items it inserts next, and allocates new nodes 64 at a
+// time, which reduces the work of the garbage collector for trees with
+// many insertions and deletions. The memory of a block of 64 nodes is
+// only released once all of them are unreachable, or on Reset.
+//
+// Disabling it releases the deleted nodes kept so far.
+func (root *Tree[T]) PoolNodes(enabled bool) {
+	if !enabled {
+		root.pool = nil
+	} else if root.pool == nil {
+		root.pool = newNodePool(root.augment)
+	}
+}
+
+// Delete every element of the tree, and release the nodes kept for
+// recycling, see PoolNodes. Every iterator on the tree becomes invalid.
+func (root *Tree[T]) Reset() {
+	root.clear()
+	if root.pool != nil {
+		root.pool = newNodePool(root.augment)
+	}
+}
+
+// Return a new node for the tree, holding item under parent.
+func (root *Tree[T]) newNode(item T, parent *node[T]) *node[T] {
+	var n *node[T]
+	if root.pool != nil {
+		n = root.pool.get()
+	} else {
+		n = root.allocNode()
+	}
+	n.item, n.parent, n.size, n.gen = item, parent, 1, root.gen
+	return n
+}
+
+// Return a copy of n that the tree may modify in place. The copy
+// keeps the reuse count of n, so that iterators redirected to it by
+// own stay valid. It is therefore never a recycled node, which stale
+// iterators may still refer to.
+func (root *Tree[T]) copyNode(n *node[T]) *node[T] {
+	var c *node[T]
+	if root.pool != nil {
+		c = root.pool.fresh()
+	} else {
+		c = root.allocNode()
+	}
+	*c = *n
+	c.gen = root.gen
+	if root.augment != nil {
+		root.augment.copyAgg(c, n)
+	}
+	return c
+}
+
+// Allocate a zeroed node, with room for an aggregate if the tree is
+// augmented.
+func (root *Tree[T]) allocNode() *node[T] {
+	if root.augment != nil {
+		return root.augment.newNode()
+	}
+	return &node[T]{}
+}
@@ -0,0 +1,114 @@
+package rbtree
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestPoolNodesRandomized(t *testing.T) {
+	o := newOracle()
+	tree := testNewIntSet()
+	tree.PoolNodes(true)
+	r := rand.New(rand.NewSource(0))
+	var snapshots []*Snapshot[Item]
+	var wants [][]int
+	for i := 0; i < 5000; i++ {
+		if r.Intn(2) == 0 || o.Len() == 0 {
+			key := r.Intn(500)
+			o.Insert(key)
+			tree.Insert(key)
+		} else {
+			key := o.RandomExistingKey(r)
+			o.Delete(key)
+			testAssert(t, tree.DeleteWithKey(key), "DeleteWithKey")
+		}
+		if i%500 == 0 {
+			// The nodes shared with snapshots must not be recycled.
+			snapshots = append(snapshots, tree.Snapshot())
+			wants = append(wants, append([]int{}, o.data...))
+		}
+	}
+	validateTree2(tree)
+	compareContentsFull(t, o, tree)
+	for i, s := range snapshots {
+		var got []int
+		for it := s.Min(); !it.Limit(); it = it.Next() {
+			got = append(got, it.Item().(int))
+		}
+		testAssert(t, len(got) == len(wants[i]), "snapshot length")
+		for j := range got {
+			testAssert(t, got[j] == wants[i][j], "snapshot contents")
+		}
+	}
+}
+
+func TestPoolNodesInvalidatesIterators(t *testing.T) {
+	tree := NewOrderedTree[int]()
+	tree.PoolNodes(true)
+	for i := 0; i < 10; i++ {
+		tree.Insert(i)
+	}
+	stale := tree.FindGE(5)
+	tree.DeleteWithKey(5)
+	// The new item reuses the node of 5.
+	tree.Insert(20)
+	testAssert(t, errors.Is(panicError(func() { stale.Item() }), ErrInvalidIterator), "recycled node")
+	testAssert(t, tree.Max().Item() == 20, "Max")
+	validateTree2(tree)
+}
+
+func TestReset(t *testing.T) {
+	tree := NewOrderedTree[int]()
+	tree.PoolNodes(true)
+	for i := 0; i < 100; i++ {
+		tree.Insert(i)
+	}
+	it := tree.Min()
+	tree.Reset()
+	testAssert(t, tree.Len() == 0 && tree.Min().Limit(), "empty after Reset")
+	testAssert(t, errors.Is(panicError(func() { it.Next() }), ErrInvalidIterator), "iterator after Reset")
+	tree.Insert(1)
+	testAssert(t, tree.Len() == 1 && tree.Min().Item() == 1, "usable after Reset")
+	validateTree2(tree)
+}
+
+// Replace random elements of a tree of 10000 ints, one per op.
+func benchmarkChurn(b *testing.B, pooled bool) {
+	tree := NewOrderedTree[int]()
+	tree.PoolNodes(pooled)
+	r := rand.New(rand.NewSource(0))
+	const size = 10000
+	for tree.Len() < size {
+		tree.Insert(r.Intn(4 * size))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.DeleteWithIterator(tree.Select(r.Intn(size)))
+		for !tree.Insert(r.Intn(4 * size)) {
+		}
+	}
+}
+
+func BenchmarkChurn(b *testing.B) {
+	b.Run("default", func(b *testing.B) { benchmarkChurn(b, false) })
+	b.Run("pooled", func(b *testing.B) { benchmarkChurn(b, true) })
+}
+
+func BenchmarkInsert(b *testing.B) {
+	for _, pooled := range []bool{false, true} {
+		name := "default"
+		if pooled {
+			name = "pooled"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			tree := NewOrderedTree[int]()
+			tree.PoolNodes(pooled)
+			for i := 0; i < b.N; i++ {
+				tree.Insert(i)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package rbtree
+
+// Bounds selects which ends of a range [lo, hi) are included. The zero
+// value is the half-open range lo <= N < hi.
+type Bounds uint8
+
+const (
+	// ExcludeLo leaves lo out of the range.
+	ExcludeLo Bounds = 1 << iota
+	// IncludeHi puts hi in the range.
+	IncludeHi
+
+	HalfOpen Bounds = 0                     // lo <= N < hi
+	Closed          = IncludeHi             // lo <= N <= hi
+	Open            = ExcludeLo             // lo < N < hi
+	LeftOpen        = ExcludeLo | IncludeHi // lo < N <= hi
+)
+
+// Report whether no element can lie between lo and hi.
+func (root *Tree[T]) emptyRange(lo, hi T, bounds Bounds) bool {
+	c := root.compare(lo, hi)
+	return c > 0 || c == 0 && bounds != Closed
+}
+
+// Remove the elements N between lo and hi, see Bounds, and return the
+// number of elements removed. The range is cut out with two splits and
+// a join, so this takes O(log n) time however many elements go, plus a
+// visit of each removed element if iterators are checked or nodes
+// pooled. As with the other deletions, only the iterators to removed
+// elements become invalid.
+func (root *Tree[T]) DeleteRange(lo, hi T, bounds Bounds) int {
+	if root.count == 0 || root.emptyRange(lo, hi, bounds) {
+		return 0
+	}
+	opts := RangeOptions{Bounds: bounds}
+	if n := root.rangeStart(lo, hi, opts); n == nil || !root.inRange(n.item, lo, hi, opts) {
+		// Leave the tree, and so its iterators, alone.
+		return 0
+	}
+	l, lh, found, rest, resth := root.split(root.root, root.blackHeight(), lo)
+	if found != nil {
+		if bounds&ExcludeLo != 0 {
+			l, lh = root.join(l, lh, found, nil, 0)
+		} else {
+			rest, resth = root.join(nil, 0, found, rest, resth)
+		}
+	}
+	mid, _, found, r, rh := root.split(rest, resth, hi)
+	removed := getSize(mid)
+	if found != nil {
+		if bounds&IncludeHi != 0 {
+			removed++
+		} else {
+			r, rh = root.join(nil, 0, found, r, rh)
+		}
+	}
+	n, _ := root.join2(l, lh, r, rh)
+	root.replaceRoot(n)
+	if !root.uncheckedIterators || root.pool != nil {
+		root.dropSubtree(mid)
+		if found != nil && bounds&IncludeHi != 0 {
+			root.dropNode(found)
+		}
+	}
+	return removed
+}
+
+// Mark the nodes of n, a subtree cut out of the tree, deleted.
+func (root *Tree[T]) dropSubtree(n *node[T]) {
+	if n == nil {
+		return
+	}
+	root.dropSubtree(n.left)
+	root.dropSubtree(n.right)
+	root.dropNode(n)
+}
+
+// Mark n, a node cut out of the tree, deleted, and recycle it unless a
+// snapshot still shares it.
+func (root *Tree[T]) dropNode(n *node[T]) {
+	owned := n.gen == root.gen
+	n.gen = deletedGen
+	if owned && root.pool != nil {
+		root.pool.put(n)
+	}
+}
+
+// RangeOptions control the elements visited by Range. The zero value
+// visits lo <= N < hi in ascending order.
+type RangeOptions struct {
+	// Bounds selects whether lo and hi themselves are visited.
+	Bounds Bounds
+	// LoUnbounded ignores lo and starts from the minimum element.
+	LoUnbounded bool
+	// HiUnbounded ignores hi and runs to the maximum element.
+	HiUnbounded bool
+	// Descending visits the elements from the largest to the smallest.
+	Descending bool
+}
+
+// Call fn on every element N between lo and hi, as selected by opts,
+// until fn returns false. fn must not modify the tree.
+func (root *Tree[T]) Range(lo, hi T, opts RangeOptions, fn func(item T) bool) {
+	for n := root.rangeStart(lo, hi, opts); n != nil && root.inRange(n.item, lo, hi, opts) && fn(n.item); {
+		if opts.Descending {
+			n = n.doPrev()
+		} else {
+			n = n.doNext()
+		}
+	}
+}
+
+// Return the first node visited by Range, or nil. The node may lie past
+// the far end of the range.
+func (root *Tree[T]) rangeStart(lo, hi T, opts RangeOptions) *node[T] {
+	if !opts.Descending {
+		if opts.LoUnbounded {
+			return root.minNode
+		}
+		n, exact := root.findGE(lo)
+		if exact && opts.Bounds&ExcludeLo != 0 {
+			n = n.doNext()
+		}
+		return n
+	}
+	if opts.HiUnbounded {
+		return root.maxNode
+	}
+	n, exact := root.findGE(hi)
+	if exact && opts.Bounds&IncludeHi != 0 {
+		return n
+	}
+	if n == nil {
+		return root.maxNode
+	}
+	return n.doPrev()
+}
+
+// Report whether item has not passed the far end of the range walked
+// by Range.
+func (root *Tree[T]) inRange(item, lo, hi T, opts RangeOptions) bool {
+	if opts.Descending {
+		if opts.LoUnbounded {
+			return true
+		}
+		c := root.compare(item, lo)
+		return c > 0 || c == 0 && opts.Bounds&ExcludeLo == 0
+	}
+	if opts.HiUnbounded {
+		return true
+	}
+	c := root.compare(item, hi)
+	return c < 0 || c == 0 && opts.Bounds&IncludeHi != 0
+}
@@ -0,0 +1,212 @@
+package rbtree
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func inBounds(k, lo, hi int, bounds Bounds) bool {
+	if k < lo || k == lo && bounds&ExcludeLo != 0 {
+		return false
+	}
+	return k < hi || k == hi && bounds&IncludeHi != 0
+}
+
+func TestDeleteRangeRandomized(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	for round := 0; round < 400; round++ {
+		tree, set := randomSet(r, r.Intn(300), 200)
+		var snapshot *Snapshot[int]
+		if round%2 == 0 {
+			snapshot = tree.Snapshot()
+		}
+		before := treeToSlice(tree)
+
+		lo, hi := r.Intn(220)-10, r.Intn(220)-10
+		bounds := Bounds(r.Intn(4))
+		want := []int{}
+		sum, removed := 0, 0
+		for k := 0; k < 200; k++ {
+			if !set[k] {
+				continue
+			}
+			if inBounds(k, lo, hi, bounds) {
+				removed++
+			} else {
+				want = append(want, k)
+				sum += k
+			}
+		}
+		assert.Equal(t, removed, tree.DeleteRange(lo, hi, bounds), "[%d, %d) %d", lo, hi, bounds)
+		assert.Equal(t, want, treeToSlice(tree))
+		if tree.Len() > 0 {
+			validateTree2(tree)
+		}
+		assert.Equal(t, sum, tree.Aggregate(-100, 300))
+		if snapshot != nil {
+			assert.Equal(t, before, snapshotToSlice(snapshot))
+		}
+		tree.Insert(lo)
+		validateTree2(tree)
+	}
+}
+
+func TestDeleteRangeKeepsIterators(t *testing.T) {
+	tree := NewOrderedTree[int]()
+	for i := 0; i < 100; i += 10 {
+		tree.Insert(i)
+	}
+	it := tree.FindGE(20)
+	assert.Equal(t, 0, tree.DeleteRange(11, 19, HalfOpen))
+	assert.Equal(t, 0, tree.DeleteRange(20, 30, Open))
+	assert.Equal(t, 0, tree.DeleteRange(91, 200, Closed))
+	assert.Equal(t, 20, it.Item())
+	gone := tree.FindGE(30)
+	assert.Equal(t, 1, tree.DeleteRange(25, 30, Closed))
+	assert.Equal(t, 20, it.Item())
+	assert.Equal(t, 40, it.Next().Item())
+	assert.True(t, errors.Is(panicError(func() { gone.Item() }), ErrInvalidIterator))
+
+	// Only the iterators to removed elements become invalid, also when
+	// split and join copy nodes shared with a snapshot, or recycle the
+	// removed ones.
+	for _, pooled := range []bool{false, true} {
+		r := rand.New(rand.NewSource(1))
+		for round := 0; round < 50; round++ {
+			tree := NewOrderedTree[int]()
+			tree.PoolNodes(pooled)
+			for i := 0; i < 200; i++ {
+				tree.Insert(r.Intn(1000))
+			}
+			var snapshot *Snapshot[int]
+			var before []int
+			if round%2 == 0 {
+				snapshot = tree.Snapshot()
+				before = snapshotToSlice(snapshot)
+			}
+			var iters []Iterator[int]
+			var items []int
+			for it := tree.Min(); !it.Limit(); it = it.Next() {
+				iters = append(iters, it)
+				items = append(items, it.Item())
+			}
+			lo := r.Intn(1000)
+			hi := lo + r.Intn(300)
+			tree.DeleteRange(lo, hi, HalfOpen)
+			validateTree2(tree)
+			for i := 0; i < 50; i++ {
+				tree.Insert(r.Intn(1000) + 1000)
+			}
+			for i, it := range iters {
+				var item int
+				err := panicError(func() { item = it.Item() })
+				if lo <= items[i] && items[i] < hi {
+					assert.True(t, errors.Is(err, ErrInvalidIterator), "removed %d", items[i])
+				} else {
+					assert.NoError(t, err)
+					assert.Equal(t, items[i], item)
+				}
+			}
+			if snapshot != nil {
+				assert.Equal(t, before, snapshotToSlice(snapshot))
+			}
+		}
+	}
+}
+
+func TestMapDeleteRange(t *testing.T) {
+	m := NewOrderedMap[int, string]()
+	for i := 0; i < 10; i++ {
+		m.Set(i, "v")
+	}
+	assert.Equal(t, 3, m.DeleteRange(2, 5, HalfOpen))
+	assert.Equal(t, 2, m.DeleteRange(5, 6, Closed))
+	assert.Equal(t, 0, m.DeleteRange(7, 7, HalfOpen))
+	assert.Equal(t, 1, m.DeleteRange(7, 7, Closed))
+	assert.Equal(t, 0, m.DeleteRange(9, 0, Closed))
+	assert.Equal(t, 1, m.DeleteRange(8, 9, LeftOpen))
+	validateTree2(m.Tree())
+	keys := []int{}
+	for it := m.Min(); !it.Limit(); it = it.Next() {
+		keys = append(keys, it.Key())
+	}
+	assert.Equal(t, []int{0, 1, 8}, keys)
+	assert.Equal(t, 1, m.DeleteRange(0, 8, Open))
+	assert.Equal(t, 2, m.Len())
+}
+
+func TestRangeRandomized(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for round := 0; round < 300; round++ {
+		tree, set := randomSet(r, r.Intn(100), 100)
+		lo, hi := r.Intn(110)-5, r.Intn(110)-5
+		opts := RangeOptions{
+			Bounds:      Bounds(r.Intn(4)),
+			LoUnbounded: r.Intn(4) == 0,
+			HiUnbounded: r.Intn(4) == 0,
+			Descending:  r.Intn(2) == 0,
+		}
+		want := []int{}
+		for k := -10; k < 110; k++ {
+			if !set[k] {
+				continue
+			}
+			if (opts.LoUnbounded || inBounds(k, lo, k+1, opts.Bounds)) &&
+				(opts.HiUnbounded || inBounds(k, k, hi, opts.Bounds&IncludeHi)) {
+				want = append(want, k)
+			}
+		}
+		if opts.Descending {
+			for i, j := 0, len(want)-1; i < j; i, j = i+1, j-1 {
+				want[i], want[j] = want[j], want[i]
+			}
+		}
+		got := []int{}
+		tree.Range(lo, hi, opts, func(item int) bool {
+			got = append(got, item)
+			return true
+		})
+		assert.Equal(t, want, got, "lo=%d hi=%d opts=%+v", lo, hi, opts)
+
+		// Stop after the first few elements.
+		limit := r.Intn(5)
+		got = got[:0]
+		tree.Range(lo, hi, opts, func(item int) bool {
+			got = append(got, item)
+			return len(got) < limit
+		})
+		if limit == 0 {
+			limit = 1
+		}
+		if limit > len(want) {
+			limit = len(want)
+		}
+		assert.Equal(t, want[:limit], got)
+	}
+}
+
+func TestMapRange(t *testing.T) {
+	m := NewOrderedMap[string, int]()
+	for i, k := range []string{"a", "b", "c", "d", "e"} {
+		m.Set(k, i)
+	}
+	var keys []string
+	var values []int
+	m.Range("b", "d", RangeOptions{Bounds: Closed, Descending: true}, func(key string, value int) bool {
+		keys = append(keys, key)
+		values = append(values, value)
+		return true
+	})
+	assert.Equal(t, []string{"d", "c", "b"}, keys)
+	assert.Equal(t, []int{3, 2, 1}, values)
+
+	keys = nil
+	m.Range("c", "", RangeOptions{Bounds: ExcludeLo, HiUnbounded: true}, func(key string, value int) bool {
+		keys = append(keys, key)
+		return true
+	})
+	assert.Equal(t, []string{"d", "e"}, keys)
+}
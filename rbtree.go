@@ -10,7 +10,10 @@
 package rbtree
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"math"
 	"strings"
 )
 
@@ -20,98 +23,165 @@ var verb bool
 // Public definitions
 //
 
-// Item is the object stored in each tree node.
-type Item=interface{}
+// Item is the object stored in each node of an untyped Tree[Item].
+//
+// Item and CompareFunc are kept for callers of the original
+// interface{}-based API: NewTree(CompareFunc) yields a *Tree[Item].
+type Item = interface{}
 
 // CompareFunc returns 0 if a==b, <0 if a<b, >0 if a>b.
 type CompareFunc func(a, b Item) int
 
-type Tree struct {
+// Tree is a red-black tree of T values ordered by a comparison function.
+type Tree[T any] struct {
 	// Root of the tree
-	root *node
+	root *node[T]
 
 	// The minimum and maximum nodes under the root.
-	minNode, maxNode *node
+	minNode, maxNode *node[T]
+
+	// Sentinel that NegativeLimit() iterators point to.
+	negativeLimitNode *node[T]
 
 	// Number of nodes under root, including the root
 	count   int
-	compare CompareFunc
+	compare func(a, b T) int
+
+	// Optional subtree aggregate kept with every node. See
+	// NewAugmentedTree.
+	augment augmenter[T]
+
+	// Generation of the nodes this tree may modify in place. Snapshot
+	// bumps it, freezing every existing node. See own.
+	gen uint64
+
+	// If set, doInsert places an item after the equal ones already in
+	// the tree instead of rejecting it. See MultiTree.
+	duplicates bool
+
+	// Bumped by the operations that invalidate every iterator on the
+	// tree, such as Split. Iterators record it when created.
+	epoch uint64
+
+	// Disables the invalidation checks. See CheckIterators.
+	uncheckedIterators bool
+
+	// Recycles deleted nodes, if set. See PoolNodes.
+	pool *nodePool[T]
+}
+
+// ErrInvalidIterator is the error that an invalidated iterator panics
+// with, see Tree.CheckIterators.
+var ErrInvalidIterator = errors.New("rbtree: use of invalidated iterator")
+
+// The gen of a node that was deleted from its tree. Trees never reach
+// this generation, so own never mistakes such a node for a writable one.
+const deletedGen = math.MaxUint64
+
+// The gen of a node that own replaced in its tree by a copy. Its parent
+// field then points to the copy, so that iterators can follow it.
+const movedGen = math.MaxUint64 - 1
+
+// Enable or disable the detection of invalidated iterators, which is on
+// by default. When enabled, using an iterator whose element was
+// deleted, or any iterator after an operation that invalidates them all
+// (Split, Join and the set operations), panics with an error wrapping
+// ErrInvalidIterator. Disabling it saves a few instructions per
+// iterator operation.
+func (root *Tree[T]) CheckIterators(enabled bool) {
+	root.uncheckedIterators = !enabled
 }
 
-// Create a new empty tree.
-func NewTree(compare CompareFunc) *Tree {
-	return &Tree{compare: compare}
+// Create an iterator pointing to n.
+func (root *Tree[T]) iterator(n *node[T]) Iterator[T] {
+	iter := Iterator[T]{root: root, node: n, epoch: root.epoch}
+	if n != nil {
+		iter.reuse = n.reuse
+	}
+	return iter
+}
+
+// Create a new empty tree. compare returns 0 if a==b, <0 if a<b, >0 if a>b.
+func NewTree[T any](compare func(a, b T) int) *Tree[T] {
+	return &Tree[T]{compare: compare, negativeLimitNode: &node[T]{}}
+}
+
+// Create a new empty tree of naturally ordered values, compared with
+// cmp.Compare.
+func NewOrderedTree[T cmp.Ordered]() *Tree[T] {
+	return NewTree(cmp.Compare[T])
 }
 
 // Return the number of elements in the tree.
-func (root *Tree) Len() int {
+func (root *Tree[T]) Len() int {
 	return root.count
 }
 
 // A convenience function for finding an element equal to key. Return
-// nil if not found.
-func (root *Tree) Get(key Item) Item {
+// the zero value of T (nil for a Tree[Item]) if not found.
+func (root *Tree[T]) Get(key T) T {
 	n, exact := root.findGE(key)
 	if exact {
 		return n.item
 	}
-	return nil
+	var zero T
+	return zero
 }
 
 // Create an iterator that points to the minimum item in the tree
 // If the tree is empty, return Limit()
-func (root *Tree) Min() Iterator {
-	return Iterator{root, root.minNode}
+func (root *Tree[T]) Min() Iterator[T] {
+	return root.iterator(root.minNode)
 }
 
 // Create an iterator that points at the maximum item in the tree
 //
 // If the tree is empty, return NegativeLimit()
-func (root *Tree) Max() Iterator {
+func (root *Tree[T]) Max() Iterator[T] {
 	if root.maxNode == nil {
 		// TODO: there are a few checks of this form.
 		// Perhaps set maxNode=negativeLimit when the tree is empty
-		return Iterator{root, negativeLimitNode}
+		return root.iterator(root.negativeLimitNode)
 	}
-	return Iterator{root, root.maxNode}
+	return root.iterator(root.maxNode)
 }
 
 // Create an iterator that points beyond the maximum item in the tree
-func (root *Tree) Limit() Iterator {
-	return Iterator{root, nil}
+func (root *Tree[T]) Limit() Iterator[T] {
+	return root.iterator(nil)
 }
 
 // Create an iterator that points before the minimum item in the tree
-func (root *Tree) NegativeLimit() Iterator {
-	return Iterator{root, negativeLimitNode}
+func (root *Tree[T]) NegativeLimit() Iterator[T] {
+	return root.iterator(root.negativeLimitNode)
 }
 
 // Find the smallest element N such that N >= key, and return the
 // iterator pointing to the element. If no such element is found,
 // return root.Limit().
-func (root *Tree) FindGE(key Item) Iterator {
+func (root *Tree[T]) FindGE(key T) Iterator[T] {
 	n, _ := root.findGE(key)
-	return Iterator{root, n}
+	return root.iterator(n)
 }
 
 // Find the largest element N such that N <= key, and return the
 // iterator pointing to the element. If no such element is found,
 // return iter.NegativeLimit().
-func (root *Tree) FindLE(key Item) Iterator {
+func (root *Tree[T]) FindLE(key T) Iterator[T] {
 	n, exact := root.findGE(key)
 	if exact {
-		return Iterator{root, n}
+		return root.iterator(n)
 	}
 	if n != nil {
-		return Iterator{root, n.doPrev()}
+		return root.prevIterator(n)
 	}
 	if root.maxNode == nil {
-		return Iterator{root, negativeLimitNode}
+		return root.iterator(root.negativeLimitNode)
 	}
-	return Iterator{root, root.maxNode}
+	return root.iterator(root.maxNode)
 }
 
-func getGU(n *node) (grandparent, uncle *node) {
+func getGU[T any](n *node[T]) (grandparent, uncle *node[T]) {
 	grandparent = n.parent.parent
 	if n.parent.isLeftChild() {
 		uncle = grandparent.right
@@ -123,21 +193,29 @@ func getGU(n *node) (grandparent, uncle *node) {
 
 // Insert an item. If the item is already in the tree, do nothing and
 // return false. Else return true.
-func (root *Tree) Insert(item Item) bool {
+func (root *Tree[T]) Insert(item T) bool {
 
 	// TODO: delay creating n until it is found to be inserted
 	n := root.doInsert(item)
 	if n == nil {
 		return false
 	}
+	root.insertFixup(n)
+	return true
+}
 
+// Restore the red-black properties after linking the new node n.
+// Return true iff the fixup painted a red root black, which adds one
+// to the black height of the tree.
+func (root *Tree[T]) insertFixup(n *node[T]) (grew bool) {
 	n.color = red
-	var uncle, grandparent *node
+	var uncle, grandparent *node[T]
 	for {
 
 		// Case 1: N is at the root
 		if n.parent == nil {
 			n.color = black
+			grew = true
 			break
 		}
 
@@ -190,12 +268,12 @@ func (root *Tree) Insert(item Item) bool {
 		}
 		break
 	}
-	return true
+	return grew
 }
 
 // Delete an item with the given key. Return true iff the item was
 // found.
-func (root *Tree) DeleteWithKey(key Item) bool {
+func (root *Tree[T]) DeleteWithKey(key T) bool {
 	n, exact := root.findGE(key)
 	if exact {
 		root.doDelete(n)
@@ -207,12 +285,101 @@ func (root *Tree) DeleteWithKey(key Item) bool {
 // Delete the current item.
 //
 // REQUIRES: !iter.Limit() && !iter.NegativeLimit()
-func (root *Tree) DeleteWithIterator(iter Iterator) {
+func (root *Tree[T]) DeleteWithIterator(iter Iterator[T]) {
 	if iter.root != root {
 		panic("DeleteWithIterator called with iterator not from this tree.")
 	}
 	doAssert(!iter.Limit() && !iter.NegativeLimit())
-	root.doDelete(iter.node)
+	root.doDelete(iter.current())
+}
+
+// Delete the current item, and return an iterator pointing to its
+// successor, or Limit().
+//
+// REQUIRES: !iter.Limit() && !iter.NegativeLimit()
+func (root *Tree[T]) DeleteAndNext(iter Iterator[T]) Iterator[T] {
+	// Deleting may move or copy the successor node; its position is
+	// what stays put.
+	i := iter.Index()
+	root.DeleteWithIterator(iter)
+	return root.Select(i)
+}
+
+// Delete the current item, and return an iterator pointing to its
+// predecessor, or NegativeLimit().
+//
+// REQUIRES: !iter.Limit() && !iter.NegativeLimit()
+func (root *Tree[T]) DeleteAndPrev(iter Iterator[T]) Iterator[T] {
+	i := iter.Index()
+	root.DeleteWithIterator(iter)
+	return root.Select(i - 1)
+}
+
+// Delete every element for which pred returns true, and return the
+// number deleted. pred is called once per element, in order, and must
+// not modify the tree.
+func (root *Tree[T]) DeleteIf(pred func(item T) bool) int {
+	deleted := 0
+	for it := root.Min(); !it.Limit(); {
+		if pred(it.Item()) {
+			it = root.DeleteAndNext(it)
+			deleted++
+		} else {
+			it = it.Next()
+		}
+	}
+	return deleted
+}
+
+// Delete every element for which keep returns false, and return the
+// number deleted. See DeleteIf.
+func (root *Tree[T]) Retain(keep func(item T) bool) int {
+	return root.DeleteIf(func(item T) bool { return !keep(item) })
+}
+
+// Return the number of elements N such that N < key.
+func (root *Tree[T]) Rank(key T) int {
+	rank := 0
+	n := root.root
+	for n != nil {
+		if root.compare(key, n.item) <= 0 {
+			n = n.left
+		} else {
+			rank += getSize(n.left) + 1
+			n = n.right
+		}
+	}
+	return rank
+}
+
+// Create an iterator that points to the i'th smallest element
+// (0-based). Return NegativeLimit() if i < 0 and Limit() if
+// i >= Len().
+func (root *Tree[T]) Select(i int) Iterator[T] {
+	if i < 0 {
+		return root.NegativeLimit()
+	}
+	n := root.root
+	for n != nil {
+		leftSize := getSize(n.left)
+		if i < leftSize {
+			n = n.left
+		} else if i == leftSize {
+			break
+		} else {
+			i -= leftSize + 1
+			n = n.right
+		}
+	}
+	return root.iterator(n)
+}
+
+// Return the number of elements N such that lo <= N < hi.
+func (root *Tree[T]) CountRange(lo, hi T) int {
+	if root.compare(lo, hi) >= 0 {
+		return 0
+	}
+	return root.Rank(hi) - root.Rank(lo)
 }
 
 // Iterator allows scanning tree elements in sort order.
@@ -220,72 +387,123 @@ func (root *Tree) DeleteWithIterator(iter Iterator) {
 // Iterator invalidation rule is the same as C++ std::map<>'s. That
 // is, if you delete the element that an iterator points to, the
 // iterator becomes invalid. For other operation types, the iterator
-// remains valid.
-type Iterator struct {
-	root *Tree
-	node *node
+// remains valid, except for those that restructure the whole tree,
+// such as Split. Using an invalid iterator panics, see CheckIterators.
+type Iterator[T any] struct {
+	root  *Tree[T]
+	node  *node[T]
+	epoch uint64
+	reuse uint32
+}
+
+// Return the node holding the current element. That is iter.node,
+// unless the tree has since replaced it by a copy, see own.
+func (iter Iterator[T]) resolve() *node[T] {
+	n := iter.node
+	for n != nil && n.gen == movedGen {
+		n = n.parent
+	}
+	return n
+}
+
+// Return the node holding the current element, and panic if the
+// iterator has been invalidated, see Tree.CheckIterators.
+func (iter Iterator[T]) current() *node[T] {
+	n := iter.resolve()
+	if iter.root.uncheckedIterators {
+		return n
+	}
+	if iter.epoch != iter.root.epoch {
+		panic(fmt.Errorf("%w: the tree was split, joined or cut since its creation", ErrInvalidIterator))
+	}
+	if n != nil && (n.gen == deletedGen || n.reuse != iter.reuse) {
+		panic(fmt.Errorf("%w: its element was deleted", ErrInvalidIterator))
+	}
+	return n
 }
 
 // allow clients to verify iterator is from the right tree.
-func (iter Iterator) Tree() *Tree {
+func (iter Iterator[T]) Tree() *Tree[T] {
 	return iter.root
 }
 
-func (iter Iterator) Equal(iter2 Iterator) bool {
-	return iter.node == iter2.node
+func (iter Iterator[T]) Equal(iter2 Iterator[T]) bool {
+	return iter.resolve() == iter2.resolve()
 }
 
 // Check if the iterator points beyond the max element in the tree
-func (iter Iterator) Limit() bool {
+func (iter Iterator[T]) Limit() bool {
 	return iter.node == nil
 }
 
 // Check if the iterator points to the minimum element in the tree
-func (iter Iterator) Min() bool {
-	return iter.node == iter.root.minNode
+func (iter Iterator[T]) Min() bool {
+	return iter.resolve() == iter.root.minNode
 }
 
 // Check if the iterator points to the maximum element in the tree
-func (iter Iterator) Max() bool {
-	return iter.node == iter.root.maxNode
+func (iter Iterator[T]) Max() bool {
+	return iter.resolve() == iter.root.maxNode
 }
 
 // Check if the iterator points before the minumum element in the tree
-func (iter Iterator) NegativeLimit() bool {
-	return iter.node == negativeLimitNode
+func (iter Iterator[T]) NegativeLimit() bool {
+	return iter.node == iter.root.negativeLimitNode
+}
+
+// Return the 0-based position of the current element in sort order.
+// Limit() is at position Len() and NegativeLimit() at -1.
+func (iter Iterator[T]) Index() int {
+	n := iter.current()
+	if iter.Limit() {
+		return iter.root.Len()
+	}
+	if iter.NegativeLimit() {
+		return -1
+	}
+	index := getSize(n.left)
+	for n.parent != nil {
+		if n.isRightChild() {
+			index += getSize(n.parent.left) + 1
+		}
+		n = n.parent
+	}
+	return index
 }
 
 // Return the current element.
 //
 // REQUIRES: !iter.Limit() && !iter.NegativeLimit()
-func (iter Iterator) Item() interface{} {
-	return iter.node.item
+func (iter Iterator[T]) Item() T {
+	return iter.current().item
 }
 
 // Create a new iterator that points to the successor of the current element.
 //
 // REQUIRES: !iter.Limit()
-func (iter Iterator) Next() Iterator {
+func (iter Iterator[T]) Next() Iterator[T] {
 	doAssert(!iter.Limit())
+	n := iter.current()
 	if iter.NegativeLimit() {
-		return Iterator{iter.root, iter.root.minNode}
+		return iter.root.iterator(iter.root.minNode)
 	}
-	return Iterator{iter.root, iter.node.doNext()}
+	return iter.root.iterator(n.doNext())
 }
 
 // Create a new iterator that points to the predecessor of the current
 // node.
 //
 // REQUIRES: !iter.NegativeLimit()
-func (iter Iterator) Prev() Iterator {
+func (iter Iterator[T]) Prev() Iterator[T] {
 	doAssert(!iter.NegativeLimit())
+	n := iter.current()
 	if !iter.Limit() {
-		return Iterator{iter.root, iter.node.doPrev()}
+		return iter.root.prevIterator(n)
 	}
 	if iter.root.maxNode == nil {
-		return Iterator{iter.root, negativeLimitNode}
+		return iter.root.NegativeLimit()
 	}
-	return Iterator{iter.root, iter.root.maxNode}
+	return iter.root.iterator(iter.root.maxNode)
 }
 
 func doAssert(b bool) {
@@ -297,34 +515,41 @@ func doAssert(b bool) {
 const red = iota
 const black = 1 + iota
 
-type node struct {
-	myTree              *Tree
-	item                Item
-	parent, left, right *node
-	color               int // black or red
+type node[T any] struct {
+	item                T
+	parent, left, right *node[T]
+	color               int32  // black or red
+	reuse               uint32 // times the node was recycled, see PoolNodes
+	size                int    // number of nodes in this subtree, including n
+	gen                 uint64
 }
 
-var negativeLimitNode *node
-
 //
 // Internal node attribute accessors
 //
-func getColor(n *node) int {
+func getColor[T any](n *node[T]) int32 {
 	if n == nil {
 		return black
 	}
 	return n.color
 }
 
-func (n *node) isLeftChild() bool {
+func getSize[T any](n *node[T]) int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *node[T]) isLeftChild() bool {
 	return n == n.parent.left
 }
 
-func (n *node) isRightChild() bool {
+func (n *node[T]) isRightChild() bool {
 	return n == n.parent.right
 }
 
-func (n *node) sibling() *node {
+func (n *node[T]) sibling() *node[T] {
 	doAssert(n.parent != nil)
 	if n.isLeftChild() {
 		return n.parent.right
@@ -334,7 +559,7 @@ func (n *node) sibling() *node {
 
 // Return the minimum node that's larger than N. Return nil if no such
 // node is found.
-func (n *node) doNext() *node {
+func (n *node[T]) doNext() *node[T] {
 	if n.right != nil {
 		m := n.right
 		for m.left != nil {
@@ -358,7 +583,7 @@ func (n *node) doNext() *node {
 
 // Return the maximum node that's smaller than N. Return nil if no
 // such node is found.
-func (n *node) doPrev() *node {
+func (n *node[T]) doPrev() *node[T] {
 	if n.left != nil {
 		return maxPredecessor(n)
 	}
@@ -373,11 +598,20 @@ func (n *node) doPrev() *node {
 		}
 		n = p
 	}
-	return negativeLimitNode
+	return nil
+}
+
+// Create an iterator that points to the predecessor of n, or
+// NegativeLimit() if n is the minimum.
+func (root *Tree[T]) prevIterator(n *node[T]) Iterator[T] {
+	if p := n.doPrev(); p != nil {
+		return root.iterator(p)
+	}
+	return root.NegativeLimit()
 }
 
 // Return the predecessor of "n".
-func maxPredecessor(n *node) *node {
+func maxPredecessor[T any](n *node[T]) *node[T] {
 	doAssert(n.left != nil)
 	m := n.left
 	for m.right != nil {
@@ -394,7 +628,7 @@ func maxPredecessor(n *node) *node {
 // Private methods
 //
 
-func (root *Tree) recomputeMinNode() {
+func (root *Tree[T]) recomputeMinNode() {
 	root.minNode = root.root
 	if root.minNode != nil {
 		for root.minNode.left != nil {
@@ -403,7 +637,7 @@ func (root *Tree) recomputeMinNode() {
 	}
 }
 
-func (root *Tree) recomputeMaxNode() {
+func (root *Tree[T]) recomputeMaxNode() {
 	root.maxNode = root.root
 	if root.maxNode != nil {
 		for root.maxNode.right != nil {
@@ -412,7 +646,7 @@ func (root *Tree) recomputeMaxNode() {
 	}
 }
 
-func (root *Tree) maybeSetMinNode(n *node) {
+func (root *Tree[T]) maybeSetMinNode(n *node[T]) {
 	if root.minNode == nil {
 		root.minNode = n
 		root.maxNode = n
@@ -421,60 +655,143 @@ func (root *Tree) maybeSetMinNode(n *node) {
 	}
 }
 
-func (root *Tree) maybeSetMaxNode(n *node) {
+func (root *Tree[T]) maybeSetMaxNode(n *node[T]) {
 	if root.maxNode == nil {
 		root.minNode = n
 		root.maxNode = n
-	} else if root.compare(n.item, root.maxNode.item) > 0 {
+	} else if root.compare(n.item, root.maxNode.item) >= 0 {
+		// An equal item is only inserted, after the max, by a MultiTree.
 		root.maxNode = n
 	}
 }
 
 // Try inserting "item" into the tree. Return nil if the item is
 // already in the tree. Otherwise return a new (leaf) node.
-func (root *Tree) doInsert(item Item) *node {
-	if root.root == nil {
-		n := &node{item: item, myTree: root}
+func (root *Tree[T]) doInsert(item T) *node[T] {
+	found, parent, comp := root.locate(item)
+	if found != nil {
+		return nil
+	}
+	return root.link(item, parent, comp)
+}
+
+// Descend from the root towards item. Return the node equal to item if
+// there is one. Otherwise return the leaf under which item belongs,
+// and comp < 0 iff it belongs on the left. parent is nil if the tree is
+// empty.
+func (root *Tree[T]) locate(item T) (found, parent *node[T], comp int) {
+	for n := root.root; n != nil; {
+		comp = root.compare(item, n.item)
+		if comp == 0 && root.duplicates {
+			comp = 1
+		}
+		if comp == 0 {
+			return n, nil, 0
+		}
+		parent = n
+		if comp < 0 {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	return nil, parent, comp
+}
+
+// Link a new leaf holding item at the place found by locate, and
+// return it. The caller must rebalance with insertFixup.
+func (root *Tree[T]) link(item T, parent *node[T], comp int) *node[T] {
+	if parent == nil {
+		n := root.newNode(item, nil)
+		root.augmentUp(n)
 		root.root = n
 		root.minNode = n
 		root.maxNode = n
 		root.count++
 		return n
 	}
-	parent := root.root
-	for true {
-		comp := root.compare(item, parent.item)
-		if comp == 0 {
-			return nil
-		} else if comp < 0 {
-			if parent.left == nil {
-				n := &node{item: item, parent: parent, myTree: root}
-				parent.left = n
-				root.count++
-				root.maybeSetMinNode(n)
-				return n
-			} else {
-				parent = parent.left
-			}
-		} else {
-			if parent.right == nil {
-				n := &node{item: item, parent: parent, myTree: root}
-				parent.right = n
-				root.count++
-				root.maybeSetMaxNode(n)
-				return n
-			} else {
-				parent = parent.right
-			}
-		}
+	parent = root.own(parent)
+	n := root.newNode(item, parent)
+	if comp < 0 {
+		parent.left = n
+		root.maybeSetMinNode(n)
+	} else {
+		parent.right = n
+		root.maybeSetMaxNode(n)
+	}
+	root.count++
+	growAncestors(n)
+	root.augmentUp(n)
+	return n
+}
+
+// Insert item, or replace the element equal to it by item. Return the
+// replaced element and true, or the zero value and false if item was
+// inserted.
+func (root *Tree[T]) ReplaceOrInsert(item T) (old T, replaced bool) {
+	found, parent, comp := root.locate(item)
+	if found != nil {
+		old = found.item
+		root.replaceItem(found, item)
+		return old, true
+	}
+	root.insertFixup(root.link(item, parent, comp))
+	return old, false
+}
+
+// Return an iterator pointing to the element equal to item, inserting
+// item first if there is none. The 2nd return value is true iff item
+// was inserted.
+func (root *Tree[T]) GetOrInsert(item T) (Iterator[T], bool) {
+	found, parent, comp := root.locate(item)
+	if found != nil {
+		return root.iterator(found), false
+	}
+	n := root.link(item, parent, comp)
+	root.insertFixup(n)
+	return root.iterator(n), true
+}
+
+// Insert, replace or delete the element equal to key in one descent.
+// fn receives the current element and whether it exists, and returns
+// the element to store, which must compare equal to key, and whether to
+// keep it. fn must not modify the tree.
+func (root *Tree[T]) update(key T, fn func(old T, exists bool) (new T, keep bool)) {
+	found, parent, comp := root.locate(key)
+	var old T
+	if found != nil {
+		old = found.item
+	}
+	item, keep := fn(old, found != nil)
+	switch {
+	case keep && found != nil:
+		root.replaceItem(found, item)
+	case keep:
+		root.insertFixup(root.link(item, parent, comp))
+	case found != nil:
+		root.doDelete(found)
+	}
+}
+
+// Overwrite the item of n with one that compares equal to it.
+func (root *Tree[T]) replaceItem(n *node[T], item T) {
+	n = root.own(n)
+	n.item = item
+	root.augmentUp(n)
+}
+
+// Add one to the subtree size of every ancestor of a newly linked
+// leaf.
+func growAncestors[T any](n *node[T]) {
+	for p := n.parent; p != nil; p = p.parent {
+		p.size++
 	}
-	panic("should not reach here")
 }
 
 // Find a node whose item >= key. The 2nd return value is true iff the
 // node.item==key. Returns (nil, false) if all nodes in the tree are <
 // key.
-func (root *Tree) findGE(key Item) (*node, bool) {
+func (root *Tree[T]) findGE(key T) (*node[T], bool) {
 	n := root.root
 	for true {
 		if n == nil {
@@ -507,12 +824,10 @@ func (root *Tree) findGE(key Item) (*node, bool) {
 }
 
 // Delete N from the tree.
-func (root *Tree) doDelete(n *node) {
-	if n.myTree != nil && n.myTree != root {
-		panic(fmt.Sprintf("delete applied to node that was not from our tree... n has tree: '%s'\n\n while root has tree: '%s'\n\n", n.myTree.DumpAsString(), root.DumpAsString()))
-	}
+func (root *Tree[T]) doDelete(n *node[T]) {
+	n = root.own(n)
 	if n.left != nil && n.right != nil {
-		pred := maxPredecessor(n)
+		pred := root.own(maxPredecessor(n))
 		root.swapNodes(n, pred)
 	}
 
@@ -525,10 +840,16 @@ func (root *Tree) doDelete(n *node) {
 		n.color = getColor(child)
 		root.deleteCase1(n)
 	}
+	parent := n.parent
+	for p := parent; p != nil; p = p.parent {
+		p.size--
+	}
 	root.replaceNode(n, child)
+	root.augmentUp(parent)
 	if n.parent == nil && child != nil {
 		child.color = black
 	}
+	n.gen = deletedGen
 	root.count--
 	if root.count == 0 {
 		root.minNode = nil
@@ -541,17 +862,21 @@ func (root *Tree) doDelete(n *node) {
 			root.recomputeMaxNode()
 		}
 	}
+	if root.pool != nil {
+		root.pool.put(n)
+	}
 }
 
 // Move n to the pred's place, and vice versa
 //
 // TODO: this code is overly convoluted
-func (root *Tree) swapNodes(n, pred *node) {
+func (root *Tree[T]) swapNodes(n, pred *node[T]) {
 	doAssert(pred != n)
 	isLeft := pred.isLeftChild()
 	tmp := *pred
 	root.replaceNode(n, pred)
 	pred.color = n.color
+	pred.size = n.size
 
 	if tmp.parent == n {
 		// swap the positions of n and pred
@@ -605,9 +930,10 @@ func (root *Tree) swapNodes(n, pred *node) {
 		}
 	}
 	n.color = tmp.color
+	n.size = tmp.size
 }
 
-func (root *Tree) deleteCase1(n *node) {
+func (root *Tree[T]) deleteCase1(n *node[T]) {
 	for true {
 		if n.parent != nil {
 			if getColor(n.sibling()) == red {
@@ -643,7 +969,7 @@ func (root *Tree) deleteCase1(n *node) {
 	}
 }
 
-func (root *Tree) deleteCase5(n *node) {
+func (root *Tree[T]) deleteCase5(n *node[T]) {
 	if n == n.parent.left &&
 		getColor(n.sibling()) == black &&
 		getColor(n.sibling().left) == red &&
@@ -674,7 +1000,49 @@ func (root *Tree) deleteCase5(n *node) {
 	}
 }
 
-func (root *Tree) replaceNode(oldn, newn *node) {
+// Return a version of n that may be modified in place. That is n
+// itself unless n predates the last Snapshot; then n is still shared
+// with the snapshot, so it is replaced in this tree by a private copy,
+// after doing the same for its ancestors.
+//
+// Snapshots never read parent pointers or colors, so those may be
+// updated on shared nodes. Everything else (item, children, size and
+// aggregate) must only be written through an owned node.
+func (root *Tree[T]) own(n *node[T]) *node[T] {
+	if n.gen == root.gen {
+		return n
+	}
+	c := root.copyNode(n)
+	// n stays in the snapshots sharing it, which ignore gen and parent.
+	n.gen = movedGen
+	if n.parent == nil {
+		root.root = c
+	} else {
+		p := root.own(n.parent)
+		if p.left == n {
+			p.left = c
+		} else {
+			p.right = c
+		}
+		c.parent = p
+	}
+	if c.left != nil {
+		c.left.parent = c
+	}
+	if c.right != nil {
+		c.right.parent = c
+	}
+	if root.minNode == n {
+		root.minNode = c
+	}
+	if root.maxNode == n {
+		root.maxNode = c
+	}
+	n.parent = c
+	return c
+}
+
+func (root *Tree[T]) replaceNode(oldn, newn *node[T]) {
 	if oldn.parent == nil {
 		root.root = newn
 	} else {
@@ -694,8 +1062,9 @@ func (root *Tree) replaceNode(oldn, newn *node) {
   A   Y	    => X   C
      B C 	  A B
 */
-func (root *Tree) rotateLeft(n *node) {
-	r := n.right
+func (root *Tree[T]) rotateLeft(n *node[T]) {
+	n = root.own(n)
+	r := root.own(n.right)
 	root.replaceNode(n, r)
 	n.right = r.left
 	if r.left != nil {
@@ -703,6 +1072,10 @@ func (root *Tree) rotateLeft(n *node) {
 	}
 	r.left = n
 	n.parent = r
+	r.size = n.size
+	n.size = getSize(n.left) + getSize(n.right) + 1
+	root.augmentNode(n)
+	root.augmentNode(r)
 
 	/*
 		y := x.right
@@ -734,8 +1107,9 @@ func (root *Tree) rotateLeft(n *node) {
    X   C  =>   A   Y
   A B             B C
 */
-func (root *Tree) rotateRight(n *node) {
-	L := n.left
+func (root *Tree[T]) rotateRight(n *node[T]) {
+	n = root.own(n)
+	L := root.own(n.left)
 	root.replaceNode(n, L)
 	n.left = L.right
 	if L.right != nil {
@@ -743,13 +1117,13 @@ func (root *Tree) rotateRight(n *node) {
 	}
 	L.right = n
 	n.parent = L
+	L.size = n.size
+	n.size = getSize(n.left) + getSize(n.right) + 1
+	root.augmentNode(n)
+	root.augmentNode(L)
 }
 
-func init() {
-	negativeLimitNode = &node{}
-}
-
-func (root *Tree) DumpAsString() string {
+func (root *Tree[T]) DumpAsString() string {
 	s := ""
 	i := 0
 	verb = true
@@ -760,7 +1134,7 @@ func (root *Tree) DumpAsString() string {
 	return s
 }
 
-func (root *Tree) Dump() {
+func (root *Tree[T]) Dump() {
 	i := 0
 	verb = true
 	for it := root.Min(); it != root.Limit(); it = it.Next() {
@@ -775,14 +1149,14 @@ func (root *Tree) Dump() {
 	root.Walk(n, 0, "root")
 }
 
-func colorString(n *node) string {
+func colorString[T any](n *node[T]) string {
 	if n.color == red {
 		return "red"
 	}
 	return "black"
 }
 
-func (tr *Tree) Walk(n *node, indent int, lab string) {
+func (tr *Tree[T]) Walk(n *node[T], indent int, lab string) {
 
 	spc := strings.Repeat(" ", indent*3)
 	var parItem, leftItem, rightItem interface{}
@@ -818,7 +1192,7 @@ func (tr *Tree) Walk(n *node, indent int, lab string) {
 
 var validations int
 
-func validateTree2(tr *Tree) {
+func validateTree2[T any](tr *Tree[T]) {
 	if tr == nil {
 		panic("can't validate a nil tree")
 	}
@@ -830,28 +1204,59 @@ func validateTree2(tr *Tree) {
 		//vv("validateTree warning, not passed the root.")
 		root = root.parent
 	}
+	if root.color != black {
+		panic("the root is red")
+	}
+	if root.size != tr.count {
+		panic("the root size doesn't match the tree's count")
+	}
+	if tr.minNode.left != nil || tr.maxNode.right != nil {
+		panic("minNode or maxNode is not at the edge of the tree")
+	}
 	tr.validateTreeHelper(root)
 	//fmt.Printf("\n tree validated\n")
 	validations++
 }
 
-func (tr *Tree) validateTreeHelper(n *node) {
+// Check the links, sizes, order and colors under n, and return the
+// number of black nodes on every path from n down to a leaf.
+func (tr *Tree[T]) validateTreeHelper(n *node[T]) int {
 
 	if n.parent != nil {
 		if n.parent.left != n && n.parent.right != n {
 			panic("my parent doesn't know me")
 		}
 	}
+	if n.size != getSize(n.left)+getSize(n.right)+1 {
+		panic("my size doesn't match my children's")
+	}
+	if n.color == red && (getColor(n.left) == red || getColor(n.right) == red) {
+		panic("double red chain found")
+	}
+	leftHeight, rightHeight := 0, 0
 	if n.left != nil {
 		if n.left.parent != n {
 			panic("my child doesn't know me")
 		}
-		tr.validateTreeHelper(n.left)
+		if c := tr.compare(n.left.item, n.item); c > 0 || c == 0 && !tr.duplicates {
+			panic("my left child is not smaller than me")
+		}
+		leftHeight = tr.validateTreeHelper(n.left)
 	}
 	if n.right != nil {
 		if n.right.parent != n {
 			panic("my child doesn't know me")
 		}
-		tr.validateTreeHelper(n.right)
+		if c := tr.compare(n.right.item, n.item); c < 0 || c == 0 && !tr.duplicates {
+			panic("my right child is not larger than me")
+		}
+		rightHeight = tr.validateTreeHelper(n.right)
+	}
+	if leftHeight != rightHeight {
+		panic("black heights of my children differ")
+	}
+	if n.color == black {
+		return leftHeight + 1
 	}
+	return leftHeight
 }
@@ -9,11 +9,13 @@ import "math/rand"
 import "fmt"
 import "log"
 import "sort"
+import "errors"
+import "strings"
 
 const testVerbose = false
 
 // Create a tree storing a set of integers
-func testNewIntSet() *Tree {
+func testNewIntSet() *Tree[Item] {
 	return NewTree(func(i1, i2 Item) int {
 		return int(i1.(int)) - int(i2.(int))
 	})
@@ -78,7 +80,7 @@ func TestDelete(t *testing.T) {
 
 }
 
-func iterToString(i Iterator) string {
+func iterToString(i Iterator[Item]) string {
 	s := ""
 	for ; !i.Limit(); i = i.Next() {
 		if s != "" { s = s + ","}
@@ -87,7 +89,7 @@ func iterToString(i Iterator) string {
 	return s
 }
 
-func reverseIterToString(i Iterator) string {
+func reverseIterToString(i Iterator[Item]) string {
 	s := ""
 	for ; !i.NegativeLimit(); i = i.Prev() {
 		if s != "" { s = s + ","}
@@ -128,6 +130,37 @@ func TestIterator(t *testing.T) {
 	}
 }
 
+func TestOrderedTree(t *testing.T) {
+	tree := NewOrderedTree[string]()
+	for _, s := range []string{"pear", "apple", "fig", "apple"} {
+		tree.Insert(s)
+	}
+	testAssert(t, tree.Len() == 3, "len==3")
+	testAssert(t, tree.Min().Item() == "apple", "min")
+	testAssert(t, tree.Max().Item() == "pear", "max")
+	testAssert(t, tree.FindGE("b").Item() == "fig", "FindGE b")
+	testAssert(t, tree.FindLE("b").Item() == "apple", "FindLE b")
+	testAssert(t, tree.FindLE("a").NegativeLimit(), "FindLE a")
+	testAssert(t, tree.Get("kiwi") == "", "Get kiwi")
+	testAssert(t, tree.DeleteWithKey("fig"), "delete fig")
+	testAssert(t, tree.FindGE("b").Item() == "pear", "FindGE b after delete")
+}
+
+func TestTypedTreeDoesNotAllocateOnLookup(t *testing.T) {
+	tree := NewOrderedTree[int64]()
+	for i := int64(0); i < 1000; i += 2 {
+		tree.Insert(i)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		for i := int64(0); i < 1000; i += 7 {
+			tree.FindGE(i)
+			tree.FindLE(i)
+			tree.Get(i)
+		}
+	})
+	testAssert(t, allocs == 0, fmt.Sprintf("lookups allocated %v times", allocs))
+}
+
 //
 // Randomized tests
 //
@@ -248,7 +281,7 @@ func (oiter oracleIterator) Prev() oracleIterator {
 	return oracleIterator{oiter.o, oiter.index - 1}
 }
 
-func compareContents(t *testing.T, oiter oracleIterator, titer Iterator) {
+func compareContents(t *testing.T, oiter oracleIterator, titer Iterator[Item]) {
 	oi := oiter
 	ti := titer
 
@@ -298,7 +331,7 @@ func compareContents(t *testing.T, oiter oracleIterator, titer Iterator) {
 	}
 }
 
-func compareContentsFull(t *testing.T, o *oracle, tree *Tree) {
+func compareContentsFull(t *testing.T, o *oracle, tree *Tree[Item]) {
 	compareContents(t, o.FindGE(t, int(-1)), tree.FindGE(-1))
 }
 
@@ -344,11 +377,217 @@ func TestRandomized(t *testing.T) {
 	}
 }
 
+func checkOrderStatistics(t *testing.T, o *oracle, tree *Tree[Item], r *rand.Rand) {
+	validateTree2(tree)
+	for i, e := range o.data {
+		it := tree.Select(i)
+		if it.Item().(int) != e {
+			t.Fatal("Select", i, it.Item(), e)
+		}
+		if it.Index() != i {
+			t.Fatal("Index", i, it.Index())
+		}
+	}
+	testAssert(t, tree.Select(-1).NegativeLimit(), "Select -1")
+	testAssert(t, tree.Select(o.Len()).Limit(), "Select Len")
+	testAssert(t, tree.Limit().Index() == o.Len(), "Limit index")
+	testAssert(t, tree.NegativeLimit().Index() == -1, "NegativeLimit index")
+
+	lo, hi := r.Intn(1000), r.Intn(1000)
+	rankLo := o.FindGE(t, lo).index
+	rankHi := o.FindGE(t, hi).index
+	testAssert(t, tree.Rank(lo) == rankLo, fmt.Sprint("Rank ", lo))
+	want := rankHi - rankLo
+	if want < 0 {
+		want = 0
+	}
+	testAssert(t, tree.CountRange(lo, hi) == want, fmt.Sprint("CountRange ", lo, hi))
+}
+
+func TestOrderStatistics(t *testing.T) {
+	o := newOracle()
+	tree := testNewIntSet()
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		if r.Intn(3) > 0 || o.Len() == 0 {
+			key := r.Intn(1000)
+			o.Insert(key)
+			tree.Insert(key)
+		} else {
+			key := o.RandomExistingKey(r)
+			o.Delete(key)
+			tree.DeleteWithKey(key)
+		}
+		if i%50 == 0 {
+			checkOrderStatistics(t, o, tree, r)
+		}
+	}
+	checkOrderStatistics(t, o, tree, r)
+}
+
+func TestReplaceOrInsert(t *testing.T) {
+	type kv struct{ key, value int }
+	tree := NewTree(func(a, b kv) int { return a.key - b.key })
+	old, replaced := tree.ReplaceOrInsert(kv{1, 10})
+	testAssert(t, !replaced && old == kv{}, "inserted into empty tree")
+	old, replaced = tree.ReplaceOrInsert(kv{1, 11})
+	testAssert(t, replaced && old == kv{1, 10}, "replaced existing item")
+	testAssert(t, tree.Get(kv{1, 0}) == kv{1, 11}, "new item stored")
+
+	it, inserted := tree.GetOrInsert(kv{1, 12})
+	testAssert(t, !inserted && it.Item() == kv{1, 11}, "GetOrInsert keeps existing item")
+	it, inserted = tree.GetOrInsert(kv{0, 5})
+	testAssert(t, inserted && it.Item() == kv{0, 5} && it.Min(), "GetOrInsert inserts missing item")
+	testAssert(t, tree.Len() == 2, "two items")
+	validateTree2(tree)
+}
+
+func TestUpsertRandomized(t *testing.T) {
+	o := newOracle()
+	tree := testNewIntSet()
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 2000; i++ {
+		key := r.Intn(500)
+		switch r.Intn(3) {
+		case 0:
+			_, replaced := tree.ReplaceOrInsert(key)
+			testAssert(t, replaced == !o.Insert(key), "ReplaceOrInsert result")
+		case 1:
+			it, inserted := tree.GetOrInsert(key)
+			testAssert(t, inserted == o.Insert(key), "GetOrInsert result")
+			testAssert(t, it.Item() == key, "GetOrInsert iterator")
+		default:
+			tree.update(key, func(old Item, exists bool) (Item, bool) {
+				return key, !exists
+			})
+			if !o.Insert(key) {
+				o.Delete(key)
+			}
+		}
+		if i%50 == 0 {
+			validateTree2(tree)
+			compareContentsFull(t, o, tree)
+		}
+	}
+}
+
+// Run fn and return the error it panics with, or nil.
+func panicError(fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(error)
+		}
+	}()
+	fn()
+	return nil
+}
+
+func TestInvalidatedIteratorPanics(t *testing.T) {
+	tree := NewOrderedTree[int]()
+	for i := 0; i < 100; i++ {
+		tree.Insert(i)
+	}
+	it := tree.FindGE(50)
+	next := it.Next()
+	tree.DeleteWithIterator(it)
+	err := panicError(func() { it.Item() })
+	testAssert(t, errors.Is(err, ErrInvalidIterator), "deleted element detected")
+	testAssert(t, strings.Contains(err.Error(), "deleted"), "error names the cause")
+	testAssert(t, errors.Is(panicError(func() { it.Next() }), ErrInvalidIterator), "Next on deleted element")
+	testAssert(t, errors.Is(panicError(func() { tree.DeleteWithIterator(it) }), ErrInvalidIterator), "double delete")
+	testAssert(t, next.Item() == 51 && next.Prev().Item() == 49, "other iterators stay valid")
+
+	// Deleting a node with two children moves its predecessor; an
+	// iterator to the predecessor stays valid.
+	root := tree.root
+	pred := tree.prevIterator(root)
+	tree.DeleteWithIterator(tree.iterator(root))
+	testAssert(t, panicError(func() { pred.Next() }) == nil, "predecessor iterator survives")
+
+	// Writing to a node shared with a snapshot copies it, and the
+	// iterator follows the copy.
+	s := tree.Snapshot()
+	it = tree.FindGE(10)
+	tree.ReplaceOrInsert(10)
+	testAssert(t, panicError(func() { it.Item() }) == nil, "copied node followed")
+	testAssert(t, s.FindGE(10).Item() == 10, "snapshot unaffected")
+	tree.DeleteWithKey(10)
+	testAssert(t, errors.Is(panicError(func() { it.Item() }), ErrInvalidIterator), "deleted copy detected")
+
+	it = tree.FindGE(20)
+	l, r := tree.Split(30)
+	err = panicError(func() { it.Item() })
+	testAssert(t, errors.Is(err, ErrInvalidIterator), "Split detected")
+	testAssert(t, strings.Contains(err.Error(), "split"), "error names the cause")
+
+	it = l.Min()
+	l.CheckIterators(false)
+	l.DeleteRange(0, 5, HalfOpen)
+	testAssert(t, panicError(func() { it.Item() }) == nil, "checks disabled")
+	testAssert(t, r.Min().Item() == 30, "other half unaffected")
+}
+
+func TestDeleteAndNext(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	for round := 0; round < 50; round++ {
+		o := newOracle()
+		tree := testNewIntSet()
+		for i := 0; i < 200; i++ {
+			key := r.Intn(1000)
+			o.Insert(key)
+			tree.Insert(key)
+		}
+		if round%2 == 0 {
+			// Deletions then copy nodes shared with the snapshot.
+			tree.Snapshot()
+		}
+		key := o.RandomExistingKey(r)
+		next := tree.DeleteAndNext(tree.FindGE(key))
+		oiter := o.FindGE(t, key).Next()
+		testAssert(t, next.Limit() == oiter.Limit(), "DeleteAndNext at the end")
+		if !next.Limit() {
+			testAssert(t, next.Item() == oiter.Item(), "DeleteAndNext successor")
+		}
+		o.Delete(key)
+
+		key = o.RandomExistingKey(r)
+		prev := tree.DeleteAndPrev(tree.FindGE(key))
+		oiter = o.FindGE(t, key).Prev()
+		testAssert(t, prev.NegativeLimit() == oiter.NegativeLimit(), "DeleteAndPrev at the start")
+		if !prev.NegativeLimit() {
+			testAssert(t, prev.Item() == oiter.Item(), "DeleteAndPrev predecessor")
+		}
+		o.Delete(key)
+		validateTree2(tree)
+		compareContentsFull(t, o, tree)
+	}
+}
+
+func TestDeleteIf(t *testing.T) {
+	tree := NewOrderedTree[int]()
+	for i := 0; i < 1000; i++ {
+		tree.Insert(i)
+	}
+	tree.Snapshot()
+	var seen []int
+	deleted := tree.DeleteIf(func(item int) bool {
+		seen = append(seen, item)
+		return item%3 != 0
+	})
+	testAssert(t, deleted == 666, "DeleteIf count")
+	testAssert(t, len(seen) == 1000 && sort.IntsAreSorted(seen), "pred called once per element in order")
+	validateTree2(tree)
+	kept := tree.Retain(func(item int) bool { return item < 300 })
+	testAssert(t, kept == 234, "Retain count")
+	testAssert(t, tree.Len() == 100 && tree.Max().Item() == 297, "Retain result")
+	validateTree2(tree)
+}
+
 //
 // Examples
 //
 
-func ExampleIntString() {
+func Example_intString() {
 	type MyItem struct {
 		key   int
 		value string
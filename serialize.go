@@ -0,0 +1,368 @@
+package rbtree
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// Serialized format
+//
+// WriteTo and Map.WriteTo produce, and ReadTree and ReadMap consume,
+// the following byte stream:
+//
+//	magic    "RBTREE"
+//	version  1 byte, currently 1
+//	kind     1 byte, 't' for a Tree or 'm' for a Map
+//	count    uvarint, the number of records
+//	records  count times: uvarint length, then length bytes of payload
+//
+// Records appear in ascending order. A Tree record's payload is the
+// Codec encoding of the element. A Map record's payload is a uvarint
+// key length, the key's encoding, then the value's encoding, which
+// takes up the rest of the record. Uvarints are encoded as by
+// encoding/binary.
+
+const (
+	serialMagic   = "RBTREE"
+	serialVersion = 1
+	serialTree    = 't'
+	serialMap     = 'm'
+
+	// Record payloads are read this many bytes at a time.
+	serialChunk = 64 << 10
+)
+
+// ErrBadFormat is returned by ReadTree and ReadMap when their input is
+// not a serialized tree or map of the expected kind and version.
+var ErrBadFormat = errors.New("rbtree: malformed serialized data")
+
+// Codec converts values of type T to and from bytes for WriteTo,
+// ReadTree and their Map counterparts.
+type Codec[T any] interface {
+	// Append the encoding of v to buf and return the extended buffer.
+	Append(buf []byte, v T) []byte
+	// Decode a value from data, which holds exactly one encoding made by
+	// Append. data is reused afterwards, so the result must not retain it.
+	Decode(data []byte) (T, error)
+}
+
+// Signed is the set of signed integer types handled by IntCodec.
+type Signed interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64
+}
+
+// Unsigned is the set of unsigned integer types handled by UintCodec.
+type Unsigned interface {
+	~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// Return a Codec for signed integers, encoded as varints.
+func IntCodec[T Signed]() Codec[T] { return intCodec[T]{} }
+
+// Return a Codec for unsigned integers, encoded as uvarints.
+func UintCodec[T Unsigned]() Codec[T] { return uintCodec[T]{} }
+
+// Return a Codec for float64, encoded as 8 little-endian bytes.
+func Float64Codec() Codec[float64] { return float64Codec{} }
+
+// Return a Codec for strings, encoded as their bytes.
+func StringCodec() Codec[string] { return stringCodec{} }
+
+// Return a Codec for byte slices, encoded as themselves.
+func BytesCodec() Codec[[]byte] { return bytesCodec{} }
+
+type intCodec[T Signed] struct{}
+
+func (intCodec[T]) Append(buf []byte, v T) []byte {
+	return binary.AppendVarint(buf, int64(v))
+}
+
+func (intCodec[T]) Decode(data []byte) (T, error) {
+	v, n := binary.Varint(data)
+	if n != len(data) || int64(T(v)) != v {
+		return 0, fmt.Errorf("bad integer %x: %w", data, ErrBadFormat)
+	}
+	return T(v), nil
+}
+
+type uintCodec[T Unsigned] struct{}
+
+func (uintCodec[T]) Append(buf []byte, v T) []byte {
+	return binary.AppendUvarint(buf, uint64(v))
+}
+
+func (uintCodec[T]) Decode(data []byte) (T, error) {
+	v, n := binary.Uvarint(data)
+	if n != len(data) || uint64(T(v)) != v {
+		return 0, fmt.Errorf("bad integer %x: %w", data, ErrBadFormat)
+	}
+	return T(v), nil
+}
+
+type float64Codec struct{}
+
+func (float64Codec) Append(buf []byte, v float64) []byte {
+	return binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+}
+
+func (float64Codec) Decode(data []byte) (float64, error) {
+	if len(data) != 8 {
+		return 0, fmt.Errorf("float64 of %d bytes: %w", len(data), ErrBadFormat)
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+}
+
+type stringCodec struct{}
+
+func (stringCodec) Append(buf []byte, v string) []byte { return append(buf, v...) }
+
+func (stringCodec) Decode(data []byte) (string, error) { return string(data), nil }
+
+type bytesCodec struct{}
+
+func (bytesCodec) Append(buf []byte, v []byte) []byte { return append(buf, v...) }
+
+func (bytesCodec) Decode(data []byte) ([]byte, error) {
+	return append([]byte{}, data...), nil
+}
+
+// Encode a Map entry as a Tree record, see the format above.
+type pairCodec[K, V any] struct {
+	key   Codec[K]
+	value Codec[V]
+}
+
+func (c pairCodec[K, V]) Append(buf []byte, p Pair[K, V]) []byte {
+	// Reserve a maximal uvarint for the key length, then shift the key
+	// down once its length is known.
+	start := len(buf)
+	buf = append(buf, make([]byte, binary.MaxVarintLen64)...)
+	buf = c.key.Append(buf, p.key)
+	keyLen := len(buf) - start - binary.MaxVarintLen64
+	n := binary.PutUvarint(buf[start:], uint64(keyLen))
+	copy(buf[start+n:], buf[start+binary.MaxVarintLen64:])
+	buf = buf[:start+n+keyLen]
+	return c.value.Append(buf, p.value)
+}
+
+func (c pairCodec[K, V]) Decode(data []byte) (p Pair[K, V], err error) {
+	keyLen, n := binary.Uvarint(data)
+	if n <= 0 || keyLen > uint64(len(data)-n) {
+		return p, fmt.Errorf("bad key length: %w", ErrBadFormat)
+	}
+	data = data[n:]
+	if p.key, err = c.key.Decode(data[:keyLen]); err != nil {
+		return p, err
+	}
+	p.value, err = c.value.Decode(data[keyLen:])
+	return p, err
+}
+
+// Write the elements of the tree to w in the format described above,
+// encoding each one with codec. Return the number of bytes written.
+func (root *Tree[T]) WriteTo(w io.Writer, codec Codec[T]) (int64, error) {
+	return root.writeTo(w, serialTree, codec)
+}
+
+// WriteTo write the pairs of the map to w, see Tree.WriteTo
+func (m Map[K, V]) WriteTo(w io.Writer, keyCodec Codec[K], valueCodec Codec[V]) (int64, error) {
+	return m.tree.writeTo(w, serialMap, pairCodec[K, V]{keyCodec, valueCodec})
+}
+
+func (root *Tree[T]) writeTo(w io.Writer, kind byte, codec Codec[T]) (int64, error) {
+	bw := bufio.NewWriter(w)
+	written := int64(0)
+	write := func(b []byte) error {
+		n, err := bw.Write(b)
+		written += int64(n)
+		return err
+	}
+	buf := append([]byte(serialMagic), serialVersion, kind)
+	buf = binary.AppendUvarint(buf, uint64(root.count))
+	if err := write(buf); err != nil {
+		return written, err
+	}
+	var payload []byte
+	for n := root.minNode; n != nil; n = n.doNext() {
+		payload = codec.Append(payload[:0], n.item)
+		buf = binary.AppendUvarint(buf[:0], uint64(len(payload)))
+		if err := write(buf); err != nil {
+			return written, err
+		}
+		if err := write(payload); err != nil {
+			return written, err
+		}
+	}
+	return written, bw.Flush()
+}
+
+// Read a tree written by WriteTo from r, decoding each element with
+// codec, and order it by compare. The tree is built in linear time as
+// the records are read, without holding them all in memory first; if
+// they are not in strictly ascending order by compare, return an error
+// wrapping ErrUnsorted.
+//
+// Unless r is an io.ByteReader, it is buffered, and data past the end
+// of the tree may be consumed.
+func ReadTree[T any](r io.Reader, codec Codec[T], compare func(a, b T) int) (*Tree[T], error) {
+	root := NewTree(compare)
+	if err := root.readFrom(r, serialTree, codec); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// ReadMap read a Map written by Map.WriteTo, see ReadTree
+func ReadMap[K, V any](r io.Reader, keyCodec Codec[K], valueCodec Codec[V], compare func(a, b K) int) (Map[K, V], error) {
+	m := NewMap[K, V](compare)
+	if err := m.tree.readFrom(r, serialMap, pairCodec[K, V]{keyCodec, valueCodec}); err != nil {
+		return Map[K, V]{}, err
+	}
+	return m, nil
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// Load the empty tree from r, see ReadTree.
+func (root *Tree[T]) readFrom(r io.Reader, kind byte, codec Codec[T]) error {
+	br, ok := r.(byteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	header := make([]byte, len(serialMagic)+2)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return fmt.Errorf("reading header: %w", unexpectedEOF(err))
+	}
+	if string(header[:len(serialMagic)]) != serialMagic {
+		return fmt.Errorf("bad magic %q: %w", header[:len(serialMagic)], ErrBadFormat)
+	}
+	if v := header[len(serialMagic)]; v != serialVersion {
+		return fmt.Errorf("unsupported version %d: %w", v, ErrBadFormat)
+	}
+	if k := header[len(serialMagic)+1]; k != kind {
+		return fmt.Errorf("kind %q, want %q: %w", k, kind, ErrBadFormat)
+	}
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return fmt.Errorf("reading count: %w", unexpectedEOF(err))
+	}
+
+	if count == 0 {
+		return nil
+	}
+	// Nodes are only allocated as their records are read, so a corrupt
+	// count fails at the end of the input.
+	if count > math.MaxInt {
+		return fmt.Errorf("count %d: %w", count, ErrBadFormat)
+	}
+	s := &treeStream[T]{root: root, r: br, codec: codec}
+	n := int(count)
+	redDepth := bits.Len(uint(n)) - 1
+	top, err := s.build(n, nil, 0, redDepth)
+	if err != nil {
+		return err
+	}
+	root.root = top
+	root.root.color = black
+	root.count = n
+	root.recomputeMinNode()
+	root.recomputeMaxNode()
+	return nil
+}
+
+// treeStream builds a tree from the records of a stream written by
+// WriteTo, in the shape built by loadSorted.
+type treeStream[T any] struct {
+	root    *Tree[T]
+	r       byteReader
+	codec   Codec[T]
+	payload []byte
+	i       int // the number of records read
+	prev    T   // the last record read
+}
+
+// Build the subtree of the next n records, in order.
+func (s *treeStream[T]) build(n int, parent *node[T], depth, redDepth int) (*node[T], error) {
+	if n == 0 {
+		return nil, nil
+	}
+	mid := n / 2
+	left, err := s.build(mid, nil, depth+1, redDepth)
+	if err != nil {
+		return nil, err
+	}
+	item, err := s.next()
+	if err != nil {
+		return nil, err
+	}
+	x := s.root.newNode(item, parent)
+	x.size, x.color = n, black
+	if depth == redDepth {
+		x.color = red
+	}
+	if x.left = left; left != nil {
+		left.parent = x
+	}
+	if x.right, err = s.build(n-mid-1, x, depth+1, redDepth); err != nil {
+		return nil, err
+	}
+	s.root.augmentNode(x)
+	return x, nil
+}
+
+// Read and decode the next record, which must sort after the previous
+// one.
+func (s *treeStream[T]) next() (item T, err error) {
+	i := s.i
+	length, err := binary.ReadUvarint(s.r)
+	if err != nil {
+		return item, fmt.Errorf("reading record %d: %w", i, unexpectedEOF(err))
+	}
+	if length > math.MaxInt32 {
+		return item, fmt.Errorf("record %d of %d bytes: %w", i, length, ErrBadFormat)
+	}
+	if s.payload, err = readPayload(s.r, s.payload[:0], int(length)); err != nil {
+		return item, fmt.Errorf("reading record %d: %w", i, unexpectedEOF(err))
+	}
+	if item, err = s.codec.Decode(s.payload); err != nil {
+		return item, fmt.Errorf("decoding record %d: %w", i, err)
+	}
+	if i > 0 && s.root.compare(s.prev, item) >= 0 {
+		return item, fmt.Errorf("record %d is not greater than record %d: %w", i, i-1, ErrUnsorted)
+	}
+	s.i, s.prev = i+1, item
+	return item, nil
+}
+
+// Append length bytes read from r to buf. The buffer grows with the
+// bytes actually read, so that a corrupt length fails at the end of the
+// input instead of allocating up to 2GiB first.
+func readPayload(r io.Reader, buf []byte, length int) ([]byte, error) {
+	for length > 0 {
+		n := min(length, serialChunk)
+		buf = slices.Grow(buf, n)
+		if _, err := io.ReadFull(r, buf[len(buf):len(buf)+n]); err != nil {
+			return buf, err
+		}
+		buf = buf[:len(buf)+n]
+		length -= n
+	}
+	return buf, nil
+}
+
+// Input that ends in the middle of a tree is always truncated.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
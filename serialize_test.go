@@ -0,0 +1,137 @@
+package rbtree
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"runtime"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTreeWriteToReadTree(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 100, 1000} {
+		tree := NewOrderedTree[int]()
+		for i := 0; i < n; i++ {
+			tree.Insert(i*7 - 300)
+		}
+		var buf bytes.Buffer
+		written, err := tree.WriteTo(&buf, IntCodec[int]())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(buf.Len()), written)
+
+		// Read through a reader that is not an io.ByteReader.
+		got, err := ReadTree(iotest.HalfReader(&buf), IntCodec[int](), cmp.Compare[int])
+		assert.NoError(t, err)
+		if n > 0 {
+			validateTree2(got)
+		}
+		assert.Equal(t, treeToSlice(tree), treeToSlice(got))
+	}
+}
+
+func TestMapWriteToReadMap(t *testing.T) {
+	m := NewOrderedMap[string, float64]()
+	m.Set("pi", math.Pi)
+	m.Set("e", math.E)
+	m.Set("", -1)
+	m.Set("inf", math.Inf(1))
+
+	var buf bytes.Buffer
+	_, err := m.WriteTo(&buf, StringCodec(), Float64Codec())
+	assert.NoError(t, err)
+	buf.WriteString("trailer")
+
+	got, err := ReadMap(&buf, StringCodec(), Float64Codec(), cmp.Compare[string])
+	assert.NoError(t, err)
+	validateTree2(got.Tree())
+	assert.Equal(t, 4, got.Len())
+	for it := m.Min(); !it.Limit(); it = it.Next() {
+		v, ok := got.Get(it.Key())
+		assert.True(t, ok)
+		assert.Equal(t, it.Value(), v)
+	}
+	// A bytes.Buffer is an io.ByteReader, so nothing past the map is read.
+	assert.Equal(t, "trailer", buf.String())
+}
+
+func TestBuiltinCodecs(t *testing.T) {
+	i8 := IntCodec[int8]()
+	for _, v := range []int8{math.MinInt8, -1, 0, 1, math.MaxInt8} {
+		got, err := i8.Decode(i8.Append(nil, v))
+		assert.NoError(t, err)
+		assert.Equal(t, v, got)
+	}
+	_, err := i8.Decode(IntCodec[int]().Append(nil, 1000))
+	assert.True(t, errors.Is(err, ErrBadFormat))
+
+	u := UintCodec[uint64]()
+	got, err := u.Decode(u.Append([]byte{}, math.MaxUint64))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(math.MaxUint64), got)
+
+	b := BytesCodec()
+	data := []byte("abc")
+	decoded, err := b.Decode(data)
+	assert.NoError(t, err)
+	data[0] = 'x'
+	assert.Equal(t, []byte("abc"), decoded)
+}
+
+func TestReadTreeErrors(t *testing.T) {
+	tree := NewOrderedTree[int]()
+	for i := 0; i < 10; i++ {
+		tree.Insert(i)
+	}
+	var buf bytes.Buffer
+	_, err := tree.WriteTo(&buf, IntCodec[int]())
+	assert.NoError(t, err)
+	data := buf.Bytes()
+
+	for i := 0; i < len(data); i++ {
+		_, err := ReadTree(bytes.NewReader(data[:i]), IntCodec[int](), cmp.Compare[int])
+		assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), "truncated at %d: %v", i, err)
+	}
+
+	_, err = ReadMap(bytes.NewReader(data), IntCodec[int](), IntCodec[int](), cmp.Compare[int])
+	assert.True(t, errors.Is(err, ErrBadFormat))
+
+	bad := append([]byte{}, data...)
+	bad[len(serialMagic)] = 2
+	_, err = ReadTree(bytes.NewReader(bad), IntCodec[int](), cmp.Compare[int])
+	assert.True(t, errors.Is(err, ErrBadFormat))
+
+	// A huge length must not be allocated before it is read.
+	huge := append([]byte(serialMagic), serialVersion, serialTree, 1)
+	huge = binary.AppendUvarint(huge, math.MaxInt32)
+	huge = append(huge, 1, 2, 3)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = ReadTree(bytes.NewReader(huge), IntCodec[int](), cmp.Compare[int])
+	runtime.ReadMemStats(&after)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(1<<20))
+
+	// Read back with the opposite order.
+	_, err = ReadTree(bytes.NewReader(data), IntCodec[int](), func(a, b int) int { return b - a })
+	assert.True(t, errors.Is(err, ErrUnsorted))
+
+	// Equal records cannot both be kept.
+	_, err = ReadTree(bytes.NewReader(data), IntCodec[int](), func(a, b int) int { return 0 })
+	assert.True(t, errors.Is(err, ErrUnsorted))
+
+	// A huge count must not be allocated before its records are read.
+	many := append([]byte(serialMagic), serialVersion, serialTree)
+	many = binary.AppendUvarint(many, math.MaxInt32)
+	many = append(many, 1, 2)
+	runtime.ReadMemStats(&before)
+	_, err = ReadTree(bytes.NewReader(many), IntCodec[int](), cmp.Compare[int])
+	runtime.ReadMemStats(&after)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(1<<20))
+}
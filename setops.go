@@ -0,0 +1,147 @@
+package rbtree
+
+// The set operations below follow Blelloch, Ferizovic and Sun, "Just
+// Join for Parallel Ordered Sets" (SPAA 2016): each splits one tree by
+// the root of the other, recurses on both sides, and joins the results.
+// For trees of sizes m <= n they run in O(m log(n/m + 1)) time.
+//
+// Like Join, they move the nodes of their arguments into the result
+// and leave both arguments empty. The result uses the comparison
+// function and augmentation of the first argument.
+
+// Return a tree holding the elements that are in a or in b. For an
+// element present in both, the one from a is kept.
+func Union[T any](a, b *Tree[T]) *Tree[T] {
+	return combineTrees(a, b, func(s *Tree[T], a *node[T], ah int, b *node[T], bh int) (*node[T], int) {
+		return s.union(a, ah, b, bh, nil)
+	})
+}
+
+// Return a tree holding the elements that are in both a and b, taken
+// from a.
+func Intersection[T any](a, b *Tree[T]) *Tree[T] {
+	return combineTrees(a, b, func(s *Tree[T], a *node[T], ah int, b *node[T], bh int) (*node[T], int) {
+		return s.intersection(a, ah, b, bh, nil)
+	})
+}
+
+// Return a tree holding the elements of a that are not in b.
+func Difference[T any](a, b *Tree[T]) *Tree[T] {
+	return combineTrees(a, b, func(s *Tree[T], a *node[T], ah int, b *node[T], bh int) (*node[T], int) {
+		return s.difference(a, ah, b, bh)
+	})
+}
+
+// Return a tree holding the elements that are in exactly one of a and b.
+func SymmetricDifference[T any](a, b *Tree[T]) *Tree[T] {
+	return combineTrees(a, b, func(s *Tree[T], a *node[T], ah int, b *node[T], bh int) (*node[T], int) {
+		return s.symmetricDifference(a, ah, b, bh)
+	})
+}
+
+// UnionMap return a Map with the keys of a and b, see Union.
+// For a key in both, merge(key, valueInA, valueInB) gives the value;
+// a nil merge keeps the value from a. a and b are left empty
+func UnionMap[K, V any](a, b Map[K, V], merge func(key K, x, y V) V) Map[K, V] {
+	mergePair := pairMerger(merge)
+	return Map[K, V]{tree: combineTrees(a.tree, b.tree, func(s *Tree[Pair[K, V]], a *node[Pair[K, V]], ah int, b *node[Pair[K, V]], bh int) (*node[Pair[K, V]], int) {
+		return s.union(a, ah, b, bh, mergePair)
+	})}
+}
+
+// IntersectionMap return a Map with the keys in both a and b, values
+// are combined as in UnionMap. a and b are left empty
+func IntersectionMap[K, V any](a, b Map[K, V], merge func(key K, x, y V) V) Map[K, V] {
+	mergePair := pairMerger(merge)
+	return Map[K, V]{tree: combineTrees(a.tree, b.tree, func(s *Tree[Pair[K, V]], a *node[Pair[K, V]], ah int, b *node[Pair[K, V]], bh int) (*node[Pair[K, V]], int) {
+		return s.intersection(a, ah, b, bh, mergePair)
+	})}
+}
+
+// DifferenceMap return a Map with the pairs of a whose key is not in b.
+// a and b are left empty
+func DifferenceMap[K, V any](a, b Map[K, V]) Map[K, V] {
+	return Map[K, V]{tree: Difference(a.tree, b.tree)}
+}
+
+// SymmetricDifferenceMap return a Map with the pairs whose key is in
+// exactly one of a and b. a and b are left empty
+func SymmetricDifferenceMap[K, V any](a, b Map[K, V]) Map[K, V] {
+	return Map[K, V]{tree: SymmetricDifference(a.tree, b.tree)}
+}
+
+func pairMerger[K, V any](merge func(key K, x, y V) V) func(x, y Pair[K, V]) Pair[K, V] {
+	if merge == nil {
+		return nil
+	}
+	return func(x, y Pair[K, V]) Pair[K, V] {
+		return Pair[K, V]{x.key, merge(x.key, x.value, y.value)}
+	}
+}
+
+// Return the union of the detached subtrees a and b, whose black
+// heights are ah and bh. For equal elements, merge(x, y) replaces the
+// element x from a, unless merge is nil.
+func (root *Tree[T]) union(a *node[T], ah int, b *node[T], bh int, merge func(x, y T) T) (*node[T], int) {
+	if a == nil {
+		return b, bh
+	}
+	if b == nil {
+		return a, ah
+	}
+	al, alh, ar, arh := detachChildren(a, ah)
+	bl, blh, found, br, brh := root.split(b, bh, a.item)
+	l, lh := root.union(al, alh, bl, blh, merge)
+	r, rh := root.union(ar, arh, br, brh, merge)
+	if found != nil && merge != nil {
+		a = root.ownDetached(a)
+		a.item = merge(a.item, found.item)
+	}
+	return root.join(l, lh, a, r, rh)
+}
+
+func (root *Tree[T]) intersection(a *node[T], ah int, b *node[T], bh int, merge func(x, y T) T) (*node[T], int) {
+	if a == nil || b == nil {
+		return nil, 0
+	}
+	al, alh, ar, arh := detachChildren(a, ah)
+	bl, blh, found, br, brh := root.split(b, bh, a.item)
+	l, lh := root.intersection(al, alh, bl, blh, merge)
+	r, rh := root.intersection(ar, arh, br, brh, merge)
+	if found == nil {
+		return root.join2(l, lh, r, rh)
+	}
+	if merge != nil {
+		a = root.ownDetached(a)
+		a.item = merge(a.item, found.item)
+	}
+	return root.join(l, lh, a, r, rh)
+}
+
+func (root *Tree[T]) difference(a *node[T], ah int, b *node[T], bh int) (*node[T], int) {
+	if a == nil || b == nil {
+		return a, ah
+	}
+	bl, blh, br, brh := detachChildren(b, bh)
+	al, alh, _, ar, arh := root.split(a, ah, b.item)
+	l, lh := root.difference(al, alh, bl, blh)
+	r, rh := root.difference(ar, arh, br, brh)
+	return root.join2(l, lh, r, rh)
+}
+
+func (root *Tree[T]) symmetricDifference(a *node[T], ah int, b *node[T], bh int) (*node[T], int) {
+	if a == nil {
+		return b, bh
+	}
+	if b == nil {
+		return a, ah
+	}
+	al, alh, ar, arh := detachChildren(a, ah)
+	bl, blh, found, br, brh := root.split(b, bh, a.item)
+	l, lh := root.symmetricDifference(al, alh, bl, blh)
+	r, rh := root.symmetricDifference(ar, arh, br, brh)
+	if found != nil {
+		return root.join2(l, lh, r, rh)
+	}
+	return root.join(l, lh, a, r, rh)
+}
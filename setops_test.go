@@ -0,0 +1,136 @@
+package rbtree
+
+import (
+	"cmp"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func randomSet(r *rand.Rand, n, max int) (*Tree[int], map[int]bool) {
+	tree := testNewSumTree()
+	set := map[int]bool{}
+	for i := 0; i < n; i++ {
+		key := r.Intn(max)
+		tree.Insert(key)
+		set[key] = true
+	}
+	return tree, set
+}
+
+func TestSetOperationsRandomized(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	ops := []struct {
+		name string
+		op   func(a, b *Tree[int]) *Tree[int]
+		keep func(inA, inB bool) bool
+	}{
+		{"Union", Union[int], func(inA, inB bool) bool { return inA || inB }},
+		{"Intersection", Intersection[int], func(inA, inB bool) bool { return inA && inB }},
+		{"Difference", Difference[int], func(inA, inB bool) bool { return inA && !inB }},
+		{"SymmetricDifference", SymmetricDifference[int], func(inA, inB bool) bool { return inA != inB }},
+	}
+	for round := 0; round < 100; round++ {
+		// Mix balanced and very uneven sizes.
+		na, nb := r.Intn(300), r.Intn(300)
+		if round%3 == 0 {
+			nb = r.Intn(5)
+		}
+		for _, op := range ops {
+			a, setA := randomSet(r, na, 500)
+			b, setB := randomSet(r, nb, 500)
+			var snapshot *Snapshot[int]
+			if round%2 == 0 {
+				snapshot = b.Snapshot()
+			}
+			bItems := treeToSlice(b)
+
+			want := []int{}
+			sum := 0
+			for k := 0; k < 500; k++ {
+				if op.keep(setA[k], setB[k]) {
+					want = append(want, k)
+					sum += k
+				}
+			}
+			result := op.op(a, b)
+			assert.Equal(t, 0, a.Len(), op.name)
+			assert.Equal(t, 0, b.Len(), op.name)
+			if result.Len() > 0 {
+				validateTree2(result)
+			}
+			assert.Equal(t, want, treeToSlice(result), op.name)
+			assert.Equal(t, sum, result.Aggregate(-1, 1000), op.name)
+			if snapshot != nil {
+				assert.Equal(t, bItems, snapshotToSlice(snapshot), op.name)
+			}
+
+			result.Insert(-5)
+			result.DeleteWithKey(-5)
+			if result.Len() > 0 {
+				validateTree2(result)
+			}
+		}
+	}
+}
+
+func TestUnionKeepsFirstElement(t *testing.T) {
+	type item struct{ key, src int }
+	compare := func(a, b item) int { return cmp.Compare(a.key, b.key) }
+	a, b := NewTree(compare), NewTree(compare)
+	for i := 0; i < 20; i += 2 {
+		a.Insert(item{i, 1})
+	}
+	for i := 0; i < 20; i += 3 {
+		b.Insert(item{i, 2})
+	}
+	u := Union(a, b)
+	validateTree2(u)
+	for it := u.Min(); !it.Limit(); it = it.Next() {
+		want := 2
+		if it.Item().key%2 == 0 {
+			want = 1
+		}
+		assert.Equal(t, want, it.Item().src, "key %d", it.Item().key)
+	}
+}
+
+func TestMapSetOperations(t *testing.T) {
+	newMap := func(keys ...int) Map[int, string] {
+		m := NewOrderedMap[int, string]()
+		for _, k := range keys {
+			m.Set(k, string(rune('a'+k)))
+		}
+		return m
+	}
+	mapToSlice := func(m Map[int, string]) []string {
+		var out []string
+		for it := m.Min(); !it.Limit(); it = it.Next() {
+			out = append(out, it.Value())
+		}
+		return out
+	}
+	concat := func(key int, x, y string) string { return x + y }
+
+	u := UnionMap(newMap(1, 2, 3), newMap(2, 3, 4), concat)
+	validateTree2(u.Tree())
+	assert.Equal(t, []string{"b", "cc", "dd", "e"}, mapToSlice(u))
+
+	u = UnionMap(newMap(1, 2), newMap(2, 3), nil)
+	assert.Equal(t, []string{"b", "c", "d"}, mapToSlice(u))
+
+	i := IntersectionMap(newMap(1, 2, 3), newMap(2, 3, 4), concat)
+	validateTree2(i.Tree())
+	assert.Equal(t, []string{"cc", "dd"}, mapToSlice(i))
+
+	d := DifferenceMap(newMap(1, 2, 3), newMap(2, 3, 4))
+	assert.Equal(t, []string{"b"}, mapToSlice(d))
+
+	s := SymmetricDifferenceMap(newMap(1, 2, 3), newMap(2, 3, 4))
+	keys := []int{}
+	for it := s.Min(); !it.Limit(); it = it.Next() {
+		keys = append(keys, it.Key())
+	}
+	assert.Equal(t, []int{1, 4}, keys)
+}
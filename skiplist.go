@@ -0,0 +1,216 @@
+package rbtree
+
+import (
+	"cmp"
+	"iter"
+	"math/rand/v2"
+)
+
+// SkipList is a skip list, an alternative backend of Ordered. Each
+// element is linked at a random number of levels, and a search skips
+// over elements along the upper levels before descending. It does no
+// rebalancing, so insertions and deletions only relink the neighbors
+// of the element, and its operations take O(log n) time on average
+// rather than in the worst case.
+type SkipList[T any] struct {
+	head    skipNode[T] // next holds the first node of each level
+	tail    *skipNode[T]
+	level   int // number of levels in use
+	count   int
+	compare func(a, b T) int
+}
+
+type skipNode[T any] struct {
+	item T
+	prev *skipNode[T] // on level 0, nil for the first node
+	next []*skipNode[T]
+}
+
+// Enough levels for 4^skipMaxLevel elements.
+const skipMaxLevel = 24
+
+// Create a new empty skip list. compare returns 0 if a==b, <0 if a<b,
+// >0 if a>b.
+func NewSkipList[T any](compare func(a, b T) int) *SkipList[T] {
+	s := &SkipList[T]{compare: compare}
+	s.head.next = make([]*skipNode[T], skipMaxLevel)
+	return s
+}
+
+// Create a new empty skip list of naturally ordered values, compared
+// with cmp.Compare.
+func NewOrderedSkipList[T cmp.Ordered]() *SkipList[T] {
+	return NewSkipList(cmp.Compare[T])
+}
+
+// Return the number of elements in the list.
+func (s *SkipList[T]) Len() int {
+	return s.count
+}
+
+// Return the number of levels to link a new node at: each level is
+// kept with probability 1/4.
+func skipLevel() int {
+	level := 1
+	for level < skipMaxLevel && rand.Uint32()&3 == 0 {
+		level++
+	}
+	return level
+}
+
+// Fill update with the last node of each level that is < key, or the
+// head, and return the last one of level 0.
+func (s *SkipList[T]) findLT(key T, update *[skipMaxLevel]*skipNode[T]) *skipNode[T] {
+	x := &s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && s.compare(x.next[i].item, key) < 0 {
+			x = x.next[i]
+		}
+		if update != nil {
+			update[i] = x
+		}
+	}
+	return x
+}
+
+// Return the first node that is >= key, or nil.
+func (s *SkipList[T]) findGE(key T) *skipNode[T] {
+	return s.findLT(key, nil).next[0]
+}
+
+// Return the last node that is <= key, or nil.
+func (s *SkipList[T]) findLE(key T) *skipNode[T] {
+	x := &s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && s.compare(x.next[i].item, key) <= 0 {
+			x = x.next[i]
+		}
+	}
+	if x == &s.head {
+		return nil
+	}
+	return x
+}
+
+// Insert an item. If the item is already in the list, do nothing and
+// return false. Else return true.
+func (s *SkipList[T]) Insert(item T) bool {
+	var update [skipMaxLevel]*skipNode[T]
+	x := s.findLT(item, &update)
+	if next := x.next[0]; next != nil && s.compare(next.item, item) == 0 {
+		return false
+	}
+	level := skipLevel()
+	for ; s.level < level; s.level++ {
+		update[s.level] = &s.head
+	}
+	n := &skipNode[T]{item: item, next: make([]*skipNode[T], level)}
+	for i := range level {
+		n.next[i] = update[i].next[i]
+		update[i].next[i] = n
+	}
+	if x != &s.head {
+		n.prev = x
+	}
+	if n.next[0] != nil {
+		n.next[0].prev = n
+	} else {
+		s.tail = n
+	}
+	s.count++
+	return true
+}
+
+// Delete an item with the given key. Return true iff the item was
+// found.
+func (s *SkipList[T]) DeleteWithKey(key T) bool {
+	var update [skipMaxLevel]*skipNode[T]
+	n := s.findLT(key, &update).next[0]
+	if n == nil || s.compare(n.item, key) != 0 {
+		return false
+	}
+	for i := range n.next {
+		update[i].next[i] = n.next[i]
+	}
+	if n.next[0] != nil {
+		n.next[0].prev = n.prev
+	} else {
+		s.tail = n.prev
+	}
+	for s.level > 0 && s.head.next[s.level-1] == nil {
+		s.level--
+	}
+	s.count--
+	return true
+}
+
+// A convenience function for finding an element equal to key. Return
+// the zero value of T if not found.
+func (s *SkipList[T]) Get(key T) T {
+	if n := s.findGE(key); n != nil && s.compare(n.item, key) == 0 {
+		return n.item
+	}
+	var zero T
+	return zero
+}
+
+func (n *skipNode[T]) get() (T, bool) {
+	if n == nil {
+		var zero T
+		return zero, false
+	}
+	return n.item, true
+}
+
+// Return the smallest element N such that N >= key, if any.
+func (s *SkipList[T]) GetGE(key T) (T, bool) {
+	return s.findGE(key).get()
+}
+
+// Return the largest element N such that N <= key, if any.
+func (s *SkipList[T]) GetLE(key T) (T, bool) {
+	return s.findLE(key).get()
+}
+
+// Return the minimum element, if any.
+func (s *SkipList[T]) GetMin() (T, bool) {
+	return s.head.next[0].get()
+}
+
+// Return the maximum element, if any.
+func (s *SkipList[T]) GetMax() (T, bool) {
+	return s.tail.get()
+}
+
+// Return a sequence of the elements in ascending order. The list must
+// not be modified while the sequence is being ranged over.
+func (s *SkipList[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for n := s.head.next[0]; n != nil && yield(n.item); n = n.next[0] {
+		}
+	}
+}
+
+// Return a sequence of the elements in descending order.
+func (s *SkipList[T]) Backward() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for n := s.tail; n != nil && yield(n.item); n = n.prev {
+		}
+	}
+}
+
+// Return a sequence of the elements N >= key in ascending order.
+func (s *SkipList[T]) AscendGE(key T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for n := s.findGE(key); n != nil && yield(n.item); n = n.next[0] {
+		}
+	}
+}
+
+// Return a sequence of the elements N <= key in descending order.
+func (s *SkipList[T]) DescendLE(key T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for n := s.findLE(key); n != nil && yield(n.item); n = n.prev {
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package rbtree
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Check the links of s, and return its elements in order.
+func validateSkipList(t *testing.T, s *SkipList[int]) []int {
+	var items []int
+	var last *skipNode[int]
+	for n := s.head.next[0]; n != nil; n = n.next[0] {
+		assert.True(t, n.prev == last)
+		items = append(items, n.item)
+		last = n
+	}
+	assert.True(t, s.tail == last)
+	assert.True(t, slices.IsSorted(items))
+	assert.Equal(t, s.Len(), len(items))
+	// Each level is sorted and skips over the nodes not linked at it.
+	for i := 0; i < skipMaxLevel; i++ {
+		if i >= s.level {
+			assert.Nil(t, s.head.next[i])
+			continue
+		}
+		assert.NotNil(t, s.head.next[i])
+		for n := s.head.next[i]; n != nil; n = n.next[i] {
+			assert.Greater(t, len(n.next), i)
+			next := n.next[0]
+			for next != nil && len(next.next) <= i {
+				next = next.next[0]
+			}
+			assert.True(t, n.next[i] == next)
+		}
+	}
+	return items
+}
+
+func TestSkipListRandomized(t *testing.T) {
+	s := NewOrderedSkipList[int]()
+	r := rand.New(rand.NewSource(0))
+	o := map[int]bool{}
+	for round := 0; round < 20; round++ {
+		for i := 0; i < 1000; i++ {
+			key := r.Intn(3000)
+			if r.Intn(20) < round {
+				assert.Equal(t, o[key], s.DeleteWithKey(key))
+				delete(o, key)
+			} else {
+				assert.Equal(t, !o[key], s.Insert(key))
+				o[key] = true
+			}
+		}
+		items := validateSkipList(t, s)
+		assert.Equal(t, len(o), len(items))
+		for _, item := range items {
+			assert.True(t, o[item])
+			assert.Equal(t, item, s.Get(item))
+		}
+	}
+}
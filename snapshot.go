@@ -0,0 +1,180 @@
+package rbtree
+
+// Snapshot is a read-only view of a Tree as of the call to
+// Tree.Snapshot. Taking a snapshot is O(1): the snapshot shares every
+// node with the tree, and the tree copies a node the first time it
+// needs to modify it afterwards, so later updates to the tree are
+// never visible through the snapshot.
+//
+// A Snapshot may be read from other goroutines while the tree keeps
+// being modified, as long as the tree itself is only used by one
+// goroutine at a time.
+type Snapshot[T any] struct {
+	root    *node[T]
+	count   int
+	compare func(a, b T) int
+}
+
+// Create a read-only view of the current contents of the tree in
+// constant time.
+//
+// Taking a snapshot does not invalidate the iterators on the tree: when
+// the tree later copies the node of an iterator, the iterator moves to
+// the copy.
+func (root *Tree[T]) Snapshot() *Snapshot[T] {
+	root.gen++
+	return &Snapshot[T]{root: root.root, count: root.count, compare: root.compare}
+}
+
+// Return the number of elements in the snapshot.
+func (s *Snapshot[T]) Len() int {
+	return s.count
+}
+
+// A convenience function for finding an element equal to key. Return
+// the zero value of T if not found.
+func (s *Snapshot[T]) Get(key T) T {
+	iter := s.FindGE(key)
+	if !iter.Limit() && s.compare(key, iter.Item()) == 0 {
+		return iter.Item()
+	}
+	var zero T
+	return zero
+}
+
+// Create an iterator that points to the minimum item in the snapshot.
+// If the snapshot is empty, return Limit().
+func (s *Snapshot[T]) Min() SnapshotIterator[T] {
+	return SnapshotIterator[T]{snapshot: s, path: appendMinPath(nil, s.root)}
+}
+
+// Create an iterator that points at the maximum item in the snapshot.
+// If the snapshot is empty, return NegativeLimit().
+func (s *Snapshot[T]) Max() SnapshotIterator[T] {
+	if s.root == nil {
+		return s.NegativeLimit()
+	}
+	return SnapshotIterator[T]{snapshot: s, path: appendMaxPath(nil, s.root)}
+}
+
+// Create an iterator that points beyond the maximum item.
+func (s *Snapshot[T]) Limit() SnapshotIterator[T] {
+	return SnapshotIterator[T]{snapshot: s}
+}
+
+// Create an iterator that points before the minimum item.
+func (s *Snapshot[T]) NegativeLimit() SnapshotIterator[T] {
+	return SnapshotIterator[T]{snapshot: s, negativeLimit: true}
+}
+
+// Find the smallest element N such that N >= key. If no such element
+// is found, return Limit().
+func (s *Snapshot[T]) FindGE(key T) SnapshotIterator[T] {
+	path := findPath(s.root, func(n *node[T]) int { return s.compare(key, n.item) }, true)
+	return SnapshotIterator[T]{snapshot: s, path: path}
+}
+
+// Find the largest element N such that N <= key. If no such element
+// is found, return NegativeLimit().
+func (s *Snapshot[T]) FindLE(key T) SnapshotIterator[T] {
+	path := findPath(s.root, func(n *node[T]) int { return s.compare(key, n.item) }, false)
+	if len(path) == 0 {
+		return s.NegativeLimit()
+	}
+	return SnapshotIterator[T]{snapshot: s, path: path}
+}
+
+// SnapshotIterator allows scanning a Snapshot in sort order. It stays
+// valid for as long as the snapshot is reachable.
+type SnapshotIterator[T any] struct {
+	snapshot *Snapshot[T]
+
+	// Path from the snapshot root to the current node. Empty at either
+	// limit. Parent pointers of shared nodes belong to the live tree,
+	// so they are never followed.
+	path          []*node[T]
+	negativeLimit bool
+}
+
+func (iter SnapshotIterator[T]) Equal(iter2 SnapshotIterator[T]) bool {
+	return iter.negativeLimit == iter2.negativeLimit && iter.current() == iter2.current()
+}
+
+// Check if the iterator points beyond the max element
+func (iter SnapshotIterator[T]) Limit() bool {
+	return len(iter.path) == 0 && !iter.negativeLimit
+}
+
+// Check if the iterator points before the minumum element
+func (iter SnapshotIterator[T]) NegativeLimit() bool {
+	return iter.negativeLimit
+}
+
+// Return the current element.
+//
+// REQUIRES: !iter.Limit() && !iter.NegativeLimit()
+func (iter SnapshotIterator[T]) Item() T {
+	return iter.current().item
+}
+
+// Create a new iterator that points to the successor of the current element.
+//
+// REQUIRES: !iter.Limit()
+func (iter SnapshotIterator[T]) Next() SnapshotIterator[T] {
+	doAssert(!iter.Limit())
+	if iter.negativeLimit {
+		return iter.snapshot.Min()
+	}
+	return SnapshotIterator[T]{snapshot: iter.snapshot, path: nextPath(iter.path)}
+}
+
+// Create a new iterator that points to the predecessor of the current
+// element.
+//
+// REQUIRES: !iter.NegativeLimit()
+func (iter SnapshotIterator[T]) Prev() SnapshotIterator[T] {
+	doAssert(!iter.NegativeLimit())
+	if iter.Limit() {
+		return iter.snapshot.Max()
+	}
+	path := prevPath(iter.path)
+	if len(path) == 0 {
+		return iter.snapshot.NegativeLimit()
+	}
+	return SnapshotIterator[T]{snapshot: iter.snapshot, path: path}
+}
+
+func (iter SnapshotIterator[T]) current() *node[T] {
+	if len(iter.path) == 0 {
+		return nil
+	}
+	return iter.path[len(iter.path)-1]
+}
+
+// MapSnapshot is a read-only view of a Map, see Tree.Snapshot
+type MapSnapshot[K, V any] struct {
+	*Snapshot[Pair[K, V]]
+}
+
+// Snapshot Create a read-only view of the map in constant time
+func (m Map[K, V]) Snapshot() MapSnapshot[K, V] {
+	return MapSnapshot[K, V]{m.tree.Snapshot()}
+}
+
+// Find the value of key as of the snapshot. The 2nd return value is
+// false iff the key was not in the map.
+func (s MapSnapshot[K, V]) Get(key K) (value V, ok bool) {
+	iter := s.FindGE(key)
+	if iter.Limit() || s.compare(Pair[K, V]{key: key}, iter.Item()) != 0 {
+		return value, false
+	}
+	return iter.Item().value, true
+}
+
+func (s MapSnapshot[K, V]) FindGE(key K) SnapshotIterator[Pair[K, V]] {
+	return s.Snapshot.FindGE(Pair[K, V]{key: key})
+}
+
+func (s MapSnapshot[K, V]) FindLE(key K) SnapshotIterator[Pair[K, V]] {
+	return s.Snapshot.FindLE(Pair[K, V]{key: key})
+}
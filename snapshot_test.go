@@ -0,0 +1,170 @@
+package rbtree
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func snapshotToSlice(s *Snapshot[int]) []int {
+	out := []int{}
+	for it := s.Min(); !it.Limit(); it = it.Next() {
+		out = append(out, it.Item())
+	}
+	return out
+}
+
+func treeToSlice(tree *Tree[int]) []int {
+	out := []int{}
+	for it := tree.Min(); !it.Limit(); it = it.Next() {
+		out = append(out, it.Item())
+	}
+	return out
+}
+
+func TestSnapshotRandomized(t *testing.T) {
+	o := newOracle()
+	tree := testNewSumTree()
+	r := rand.New(rand.NewSource(0))
+
+	type version struct {
+		snapshot *Snapshot[int]
+		data     []int
+	}
+	var versions []version
+	for i := 0; i < 4000; i++ {
+		if r.Intn(3) > 0 || o.Len() == 0 {
+			key := r.Intn(500)
+			assert.Equal(t, o.Insert(key), tree.Insert(key))
+		} else {
+			key := o.RandomExistingKey(r)
+			o.Delete(key)
+			assert.True(t, tree.DeleteWithKey(key))
+		}
+		if i%97 == 0 {
+			versions = append(versions, version{tree.Snapshot(), append([]int{}, o.data...)})
+		}
+		if i%50 == 0 {
+			validateTree2(tree)
+			assert.Equal(t, o.data, treeToSlice(tree))
+			sum := 0
+			for _, k := range o.data {
+				sum += k
+			}
+			assert.Equal(t, sum, tree.Aggregate(0, 500))
+		}
+	}
+	for _, v := range versions {
+		assert.Equal(t, len(v.data), v.snapshot.Len())
+		assert.Equal(t, v.data, snapshotToSlice(v.snapshot))
+		if len(v.data) > 0 {
+			key := v.data[len(v.data)/2]
+			assert.Equal(t, key, v.snapshot.Get(key))
+			assert.Equal(t, key, v.snapshot.FindLE(key).Item())
+			assert.Equal(t, v.data[len(v.data)-1], v.snapshot.Max().Item())
+			assert.Equal(t, v.data[len(v.data)-1], v.snapshot.Limit().Prev().Item())
+		}
+	}
+}
+
+func TestSnapshotOfEmptyTree(t *testing.T) {
+	tree := NewOrderedTree[int]()
+	s := tree.Snapshot()
+	tree.Insert(1)
+	assert.Equal(t, 0, s.Len())
+	assert.True(t, s.Min().Limit())
+	assert.True(t, s.Max().NegativeLimit())
+	assert.True(t, s.FindGE(0).Limit())
+	assert.True(t, s.FindLE(5).NegativeLimit())
+}
+
+func TestIteratorsSurviveSnapshot(t *testing.T) {
+	for _, pooled := range []bool{false, true} {
+		tree := NewOrderedTree[int]()
+		tree.PoolNodes(pooled)
+		for i := 0; i < 100; i += 2 {
+			tree.Insert(i)
+		}
+		before := tree.FindGE(50)
+		s := tree.Snapshot()
+		after := tree.FindGE(40)
+		min, max := tree.Min(), tree.Max()
+		// Copies every node on the paths to 41 and 51.
+		tree.Insert(41)
+		tree.Insert(51)
+		tree.DeleteWithKey(0)
+		assert.Equal(t, 50, before.Item())
+		assert.Equal(t, 51, before.Next().Item())
+		assert.Equal(t, 48, before.Prev().Item())
+		assert.Equal(t, 40, after.Item())
+		assert.Equal(t, 41, after.Next().Item())
+		assert.Equal(t, 19, after.Index())
+		assert.True(t, after.Next().Next().Equal(tree.FindGE(42)))
+		assert.True(t, max.Max())
+		assert.False(t, min.Min())
+		assert.Equal(t, 50, s.Len())
+
+		tree.DeleteWithIterator(before)
+		assert.True(t, errors.Is(panicError(func() { before.Item() }), ErrInvalidIterator))
+		assert.Equal(t, 51, tree.FindGE(50).Item())
+		validateTree2(tree)
+	}
+}
+
+func TestMapSnapshot(t *testing.T) {
+	m := NewOrderedMap[string, int]()
+	m.Set("a", 1)
+	m.Set("b", 2)
+	s := m.Snapshot()
+	m.Set("a", 10)
+	m.Set("c", 3)
+	m.DeleteWithKey("b")
+
+	v, ok := s.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	v, ok = s.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+	_, ok = s.Get("c")
+	assert.False(t, ok)
+	assert.Equal(t, "b", s.FindGE("aa").Item().Key())
+	assert.Equal(t, 2, s.Len())
+
+	v, _ = m.Get("a")
+	assert.Equal(t, 10, v)
+	assert.Equal(t, 2, m.Len())
+}
+
+func TestSnapshotReadWhileWriting(t *testing.T) {
+	tree := NewOrderedTree[int]()
+	for i := 0; i < 1000; i++ {
+		tree.Insert(i)
+	}
+	s := tree.Snapshot()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for round := 0; round < 5; round++ {
+			data := snapshotToSlice(s)
+			if len(data) != 1000 || data[0] != 0 || data[999] != 999 {
+				t.Error("snapshot changed under a concurrent writer")
+			}
+		}
+	}()
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 5000; i++ {
+		if r.Intn(2) == 0 {
+			tree.Insert(r.Intn(2000))
+		} else {
+			tree.DeleteWithKey(r.Intn(2000))
+		}
+	}
+	wg.Wait()
+	validateTree2(tree)
+}
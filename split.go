@@ -0,0 +1,259 @@
+package rbtree
+
+// Split moves the elements of the tree into two new trees: left holds
+// the elements N < key and right the elements N >= key. It runs in
+// O(log n) time and leaves the receiver empty; iterators on it become
+// invalid.
+func (root *Tree[T]) Split(key T) (left, right *Tree[T]) {
+	left, right = root.emptyLike(), root.emptyLike()
+	l, _, found, r, rh := root.split(root.root, root.blackHeight(), key)
+	if found != nil {
+		r, _ = root.join(nil, 0, found, r, rh)
+	}
+	left.setRoot(l)
+	right.setRoot(r)
+	root.clear()
+	return left, right
+}
+
+// Join moves the elements of left and right into a new tree in
+// O(log n) time, and leaves left and right empty. The new tree uses
+// left's comparison function, augmentation and iterator checking.
+//
+// REQUIRES: every element of left is smaller than every element of right
+func Join[T any](left, right *Tree[T]) *Tree[T] {
+	if left.count > 0 && right.count > 0 && left.compare(left.maxNode.item, right.minNode.item) >= 0 {
+		panic("Join called with overlapping trees")
+	}
+	return combineTrees(left, right, func(s *Tree[T], l *node[T], lh int, r *node[T], rh int) (*node[T], int) {
+		return s.join2(l, lh, r, rh)
+	})
+}
+
+// Move the elements of a and b into a new tree built by op, which
+// receives the root of each tree and its black height and returns the
+// root and black height of the result. op may use its first argument,
+// the new tree, as scratch space for join. a and b are left empty.
+func combineTrees[T any](a, b *Tree[T], op func(s *Tree[T], a *node[T], ah int, b *node[T], bh int) (*node[T], int)) *Tree[T] {
+	if a.augment != nil && b.count > 0 && !a.augment.sameType(b.augment) {
+		// The nodes of b have no room for the aggregates of a.
+		panic("cannot combine trees with different aggregate types")
+	}
+	result := a.emptyLike()
+	if b.gen > result.gen {
+		// Nodes of either tree may then look writable to the other;
+		// using the larger generation makes the older tree's nodes
+		// look frozen, which is always safe.
+		result.gen = b.gen
+	}
+	n, _ := op(result, a.root, a.blackHeight(), b.root, b.blackHeight())
+	a.clear()
+	b.clear()
+	result.setRoot(n)
+	return result
+}
+
+// Create an empty tree with the same comparison, augmentation,
+// generation, iterator checking and node pooling as root.
+func (root *Tree[T]) emptyLike() *Tree[T] {
+	t := NewTree(root.compare)
+	t.augment = root.augment
+	t.gen = root.gen
+	t.uncheckedIterators = root.uncheckedIterators
+	if root.pool != nil {
+		t.pool = newNodePool(t.augment)
+	}
+	return t
+}
+
+// Make n, a detached subtree with a black root, the contents of the
+// tree, and invalidate its iterators.
+func (root *Tree[T]) setRoot(n *node[T]) {
+	root.epoch++
+	root.replaceRoot(n)
+}
+
+// Make n the contents of the tree like setRoot, but leave iterators
+// valid. The caller must make sure that every node they point to is
+// still in the tree, was moved by own or ownDetached, or is marked
+// deleted.
+func (root *Tree[T]) replaceRoot(n *node[T]) {
+	root.root = n
+	root.count = getSize(n)
+	if n == nil {
+		root.minNode, root.maxNode = nil, nil
+		return
+	}
+	n.parent = nil
+	root.recomputeMinNode()
+	root.recomputeMaxNode()
+}
+
+func (root *Tree[T]) clear() {
+	root.epoch++
+	root.root, root.minNode, root.maxNode = nil, nil, nil
+	root.count = 0
+}
+
+// Return the number of black nodes on a path from the root to a leaf.
+func (root *Tree[T]) blackHeight() int {
+	h := 0
+	for n := root.root; n != nil; n = n.left {
+		if n.color == black {
+			h++
+		}
+	}
+	return h
+}
+
+// Unlink the subtree n, whose black height is h, from its parent and
+// paint its root black. Return n and its new black height.
+func detach[T any](n *node[T], h int) (*node[T], int) {
+	if n == nil {
+		return nil, 0
+	}
+	n.parent = nil
+	if n.color == red {
+		n.color = black
+		h++
+	}
+	return n, h
+}
+
+// Return a writable version of n, which is about to be relinked by
+// join. Unlike own, the copy is not linked anywhere; n is marked moved,
+// as by own, so that its iterators follow it to the copy.
+func (root *Tree[T]) ownDetached(n *node[T]) *node[T] {
+	if n.gen == root.gen {
+		return n
+	}
+	c := root.copyNode(n)
+	n.gen, n.parent = movedGen, c
+	return c
+}
+
+// Detach the children of n, whose black height is h, and return them
+// with their black heights.
+func detachChildren[T any](n *node[T], h int) (l *node[T], lh int, r *node[T], rh int) {
+	if n.color == black {
+		h--
+	}
+	l, lh = detach(n.left, h)
+	r, rh = detach(n.right, h)
+	return l, lh, r, rh
+}
+
+// Split the subtree n, whose black height is h, into subtrees of the
+// elements < key and > key, and return them with their black heights.
+// found is the detached node equal to key, or nil.
+func (root *Tree[T]) split(n *node[T], h int, key T) (l *node[T], lh int, found *node[T], r *node[T], rh int) {
+	if n == nil {
+		return nil, 0, nil, nil, 0
+	}
+	left, leftHeight, right, rightHeight := detachChildren(n, h)
+	c := root.compare(key, n.item)
+	if c == 0 {
+		return left, leftHeight, n, right, rightHeight
+	} else if c < 0 {
+		l, lh, found, r, rh = root.split(left, leftHeight, key)
+		r, rh = root.join(r, rh, n, right, rightHeight)
+	} else {
+		l, lh, found, r, rh = root.split(right, rightHeight, key)
+		l, lh = root.join(left, leftHeight, n, l, lh)
+	}
+	return l, lh, found, r, rh
+}
+
+// Remove the minimum node from the subtree n, whose black height is h.
+// Return the rest of the subtree, its black height and the detached
+// minimum node.
+func (root *Tree[T]) splitMin(n *node[T], h int) (rest *node[T], restHeight int, min *node[T]) {
+	left, leftHeight, right, rightHeight := detachChildren(n, h)
+	if left == nil {
+		return right, rightHeight, n
+	}
+	rest, restHeight, min = root.splitMin(left, leftHeight)
+	rest, restHeight = root.join(rest, restHeight, n, right, rightHeight)
+	return rest, restHeight, min
+}
+
+// Join the subtrees l and r like join, without a middle node.
+func (root *Tree[T]) join2(l *node[T], lh int, r *node[T], rh int) (*node[T], int) {
+	if r == nil {
+		return l, lh
+	}
+	r, rh, min := root.splitMin(r, rh)
+	return root.join(l, lh, min, r, rh)
+}
+
+// Join the subtrees l and r, whose roots are black and detached, with
+// the node k in between. lh and rh are the black heights of l and r.
+// Return the root of the result, which is black, and its black height.
+//
+// The node k is linked into the taller subtree at the depth where the
+// shorter one fits, so this takes O(|lh - rh| + 1) time plus the
+// rebalancing of a single insertion. root is used as scratch space.
+func (root *Tree[T]) join(l *node[T], lh int, k *node[T], r *node[T], rh int) (*node[T], int) {
+	k = root.ownDetached(k)
+	if lh == rh {
+		k.parent, k.left, k.right = nil, l, r
+		if l != nil {
+			l.parent = k
+		}
+		if r != nil {
+			r.parent = k
+		}
+		k.color = black
+		k.size = getSize(l) + getSize(r) + 1
+		root.augmentNode(k)
+		return k, lh + 1
+	}
+
+	tall, h := l, lh
+	if rh > lh {
+		tall, h = r, rh
+	}
+	root.root = tall
+	// Walk down the inner spine of the taller tree to the first black
+	// node c whose black height is that of the shorter tree.
+	var p *node[T]
+	c, ch := tall, h
+	for ch > lh || ch > rh || getColor(c) == red {
+		if getColor(c) == black {
+			ch--
+		}
+		p = c
+		if lh > rh {
+			c = c.right
+		} else {
+			c = c.left
+		}
+	}
+	p = root.own(p)
+	k.parent, k.color = p, red
+	if lh > rh {
+		k.left, k.right = c, r
+		p.right = k
+	} else {
+		k.left, k.right = l, c
+		p.left = k
+	}
+	if k.left != nil {
+		k.left.parent = k
+	}
+	if k.right != nil {
+		k.right.parent = k
+	}
+	k.size = getSize(k.left) + getSize(k.right) + 1
+	growth := k.size - getSize(c)
+	for a := p; a != nil; a = a.parent {
+		a.size += growth
+	}
+	root.augmentUp(k)
+	if root.insertFixup(k) {
+		h++
+	}
+	result := root.root
+	root.root = nil
+	return result, h
+}
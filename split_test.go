@@ -0,0 +1,108 @@
+package rbtree
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitJoinRandomized(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	for round := 0; round < 200; round++ {
+		tree := testNewSumTree()
+		o := newOracle()
+		n := r.Intn(300)
+		for i := 0; i < n; i++ {
+			key := r.Intn(1000)
+			tree.Insert(key)
+			o.Insert(key)
+		}
+		var snapshot *Snapshot[int]
+		if round%2 == 0 {
+			snapshot = tree.Snapshot()
+		}
+
+		key := r.Intn(1100) - 50
+		left, right := tree.Split(key)
+		assert.Equal(t, 0, tree.Len())
+		validateTree2(left)
+		validateTree2(right)
+		idx := o.FindGE(t, key).index
+		assert.Equal(t, o.data[:idx], treeToSlice(left))
+		assert.Equal(t, o.data[idx:], treeToSlice(right))
+		sum := 0
+		for _, k := range o.data[:idx] {
+			sum += k
+		}
+		assert.Equal(t, sum, left.Aggregate(-100, 2000))
+
+		// The halves remain fully functional trees.
+		left.Insert(-1000)
+		right.Insert(5000)
+		validateTree2(left)
+		validateTree2(right)
+		left.DeleteWithKey(-1000)
+		right.DeleteWithKey(5000)
+
+		joined := Join(left, right)
+		assert.Equal(t, 0, left.Len())
+		assert.Equal(t, 0, right.Len())
+		validateTree2(joined)
+		assert.Equal(t, o.data, treeToSlice(joined))
+		if snapshot != nil {
+			assert.Equal(t, o.data, snapshotToSlice(snapshot))
+		}
+	}
+}
+
+func TestJoinUnevenTrees(t *testing.T) {
+	for _, sizes := range [][2]int{{0, 0}, {0, 5}, {5, 0}, {1, 1000}, {1000, 1}, {37, 400}, {400, 37}} {
+		left, right := NewOrderedTree[int](), NewOrderedTree[int]()
+		want := []int{}
+		for i := 0; i < sizes[0]; i++ {
+			left.Insert(i)
+			want = append(want, i)
+		}
+		for i := 0; i < sizes[1]; i++ {
+			right.Insert(10000 + i)
+			want = append(want, 10000+i)
+		}
+		joined := Join(left, right)
+		if joined.Len() > 0 {
+			validateTree2(joined)
+		}
+		assert.Equal(t, want, treeToSlice(joined), "sizes %v", sizes)
+		assert.Equal(t, len(want), joined.Len())
+	}
+}
+
+func TestJoinOverlappingPanics(t *testing.T) {
+	left, right := NewOrderedTree[int](), NewOrderedTree[int]()
+	left.Insert(5)
+	right.Insert(5)
+	assert.Panics(t, func() { Join(left, right) })
+}
+
+func TestCombinedTreesKeepIteratorChecking(t *testing.T) {
+	ops := map[string]func(a, b *Tree[int]) *Tree[int]{
+		"Split":        func(a, b *Tree[int]) *Tree[int] { l, _ := a.Split(50); return l },
+		"Join":         func(a, b *Tree[int]) *Tree[int] { _, r := b.Split(100); return Join(a, r) },
+		"Union":        Union[int],
+		"Intersection": Intersection[int],
+		"Difference":   Difference[int],
+	}
+	for name, op := range ops {
+		a, b := NewOrderedTree[int](), NewOrderedTree[int]()
+		for i := 0; i < 100; i++ {
+			a.Insert(i)
+			b.Insert(100 + i)
+			b.Insert(i / 10)
+		}
+		a.CheckIterators(false)
+		result := op(a, b)
+		it := result.Min()
+		result.DeleteWithIterator(it)
+		assert.Nil(t, panicError(func() { it.Item() }), name)
+	}
+}
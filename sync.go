@@ -0,0 +1,229 @@
+package rbtree
+
+import "sync"
+
+// SyncTree is a Tree that is safe for concurrent use. Lookups and
+// iteration take a read lock, updates take the write lock, and the
+// compound operations below run atomically under a single write lock.
+//
+// SyncTree hands out no iterators, since they would outlive the lock;
+// iteration is done with callbacks instead. The callbacks run with the
+// read lock held, so they must not modify the tree.
+type SyncTree[T any] struct {
+	mu   sync.RWMutex
+	tree *Tree[T]
+}
+
+// Create a new empty concurrency-safe tree.
+func NewSyncTree[T any](compare func(a, b T) int) *SyncTree[T] {
+	return &SyncTree[T]{tree: NewTree(compare)}
+}
+
+// Return the number of elements in the tree.
+func (t *SyncTree[T]) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.tree.Len()
+}
+
+// Find the element equal to key. The 2nd return value is false iff
+// there is none.
+func (t *SyncTree[T]) Get(key T) (item T, ok bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	n, exact := t.tree.findGE(key)
+	if !exact {
+		return item, false
+	}
+	return n.item, true
+}
+
+// Insert an item. If the item is already in the tree, do nothing and
+// return false. Else return true.
+func (t *SyncTree[T]) Insert(item T) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tree.Insert(item)
+}
+
+// Delete an item with the given key. Return true iff the item was
+// found.
+func (t *SyncTree[T]) DeleteWithKey(key T) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tree.DeleteWithKey(key)
+}
+
+// Return the element equal to item if there is one. Otherwise insert
+// item and return it. The 2nd return value is true iff item was
+// inserted.
+func (t *SyncTree[T]) GetOrInsert(item T) (actual T, inserted bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	it, inserted := t.tree.GetOrInsert(item)
+	return it.Item(), inserted
+}
+
+// Replace the element equal to old with new, if equal(element, old).
+// Return true iff the swap happened. new must compare equal to old,
+// since it takes old's place in the tree; if it does not, nothing is
+// swapped and false is returned. T need not be comparable: equal
+// decides what counts as the same element.
+func (t *SyncTree[T]) CompareAndSwap(old, new T, equal func(a, b T) bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tree.compare(new, old) != 0 {
+		return false
+	}
+	n, exact := t.tree.findGE(old)
+	if !exact || !equal(n.item, old) {
+		return false
+	}
+	t.tree.replaceItem(n, new)
+	return true
+}
+
+// Atomically update the element equal to key. fn receives the current
+// element and whether it exists, and returns the element to store and
+// whether to keep it: returning keep=false deletes the element (or
+// inserts nothing). The stored element must compare equal to key.
+func (t *SyncTree[T]) Update(key T, fn func(old T, exists bool) (new T, keep bool)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tree.update(key, fn)
+}
+
+// Call fn on every element in ascending order until it returns false.
+func (t *SyncTree[T]) Ascend(fn func(item T) bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for it := t.tree.Min(); !it.Limit() && fn(it.Item()); it = it.Next() {
+	}
+}
+
+// Call fn on every element N with lo <= N < hi in ascending order until
+// it returns false.
+func (t *SyncTree[T]) AscendRange(lo, hi T, fn func(item T) bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	t.tree.Range(lo, hi, RangeOptions{}, fn)
+}
+
+// Call fn on every element in descending order until it returns false.
+func (t *SyncTree[T]) Descend(fn func(item T) bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for it := t.tree.Max(); !it.NegativeLimit() && fn(it.Item()); it = it.Prev() {
+	}
+}
+
+// Take a Snapshot of the tree. The snapshot may be iterated without
+// holding any lock while the tree keeps being updated.
+func (t *SyncTree[T]) Snapshot() *Snapshot[T] {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tree.Snapshot()
+}
+
+// SyncMap is a Map that is safe for concurrent use, see SyncTree
+type SyncMap[K, V any] struct {
+	mu sync.RWMutex
+	m  Map[K, V]
+}
+
+// NewSyncMap Create a new empty concurrency-safe Map
+func NewSyncMap[K, V any](compare func(a, b K) int) *SyncMap[K, V] {
+	return &SyncMap[K, V]{m: NewMap[K, V](compare)}
+}
+
+func (m *SyncMap[K, V]) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.m.Len()
+}
+
+// Find the value of key. The 2nd return value is false iff the key is
+// not in the map.
+func (m *SyncMap[K, V]) Get(key K) (value V, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.m.Get(key)
+}
+
+// Set the value of key, adding the key if it is new. Return true iff
+// the key was already in the map.
+func (m *SyncMap[K, V]) Set(key K, value V) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.m.Set(key, value)
+}
+
+func (m *SyncMap[K, V]) DeleteWithKey(key K) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.m.DeleteWithKey(key)
+}
+
+// GetOrInsert return the value of key if exist, else set key to value
+// inserted: true if value was set
+func (m *SyncMap[K, V]) GetOrInsert(key K, value V) (actual V, inserted bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, inserted := m.m.tree.GetOrInsert(Pair[K, V]{key, value})
+	return it.Item().value, inserted
+}
+
+// Set the value of key to new, if the key is present and
+// equal(value, old). Return true iff the swap happened. V need not be
+// comparable: equal decides what counts as the same value.
+func (m *SyncMap[K, V]) CompareAndSwap(key K, old, new V, equal func(a, b V) bool) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, found := m.m.tree.findGE(Pair[K, V]{key: key})
+	if !found || !equal(n.item.value, old) {
+		return false
+	}
+	m.m.tree.replaceItem(n, Pair[K, V]{n.item.key, new})
+	return true
+}
+
+// Atomically update the value of key. fn receives the current value
+// and whether the key exists, and returns the value to store and
+// whether to keep the key: returning keep=false deletes the key (or
+// inserts nothing).
+func (m *SyncMap[K, V]) Update(key K, fn func(old V, exists bool) (new V, keep bool)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.m.Update(key, fn)
+}
+
+// Ascend call fn on every pair in key order until fn return false
+func (m *SyncMap[K, V]) Ascend(fn func(key K, value V) bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for it := m.m.Min(); !it.Limit() && fn(it.Key(), it.Value()); it = it.Next() {
+	}
+}
+
+// AscendRange call fn on pairs with lo <= key < hi in key order until
+// fn return false
+func (m *SyncMap[K, V]) AscendRange(lo, hi K, fn func(key K, value V) bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	m.m.Range(lo, hi, RangeOptions{}, fn)
+}
+
+// Descend call fn on every pair in reverse key order until fn return false
+func (m *SyncMap[K, V]) Descend(fn func(key K, value V) bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for it := m.m.Max(); !it.NegativeLimit() && fn(it.Key(), it.Value()); it = it.Prev() {
+	}
+}
+
+// Snapshot take a snapshot that may be read without lock, see SyncTree.Snapshot
+func (m *SyncMap[K, V]) Snapshot() MapSnapshot[K, V] {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.m.Snapshot()
+}
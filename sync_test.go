@@ -0,0 +1,144 @@
+package rbtree
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncTreeConcurrent(t *testing.T) {
+	tree := NewSyncTree(cmp.Compare[int])
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				tree.Insert(w*1000 + i)
+				if i%3 == 0 {
+					tree.DeleteWithKey(w*1000 + i/2)
+				}
+			}
+		}(w)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				prev := -1
+				tree.Ascend(func(item int) bool {
+					if item <= prev {
+						t.Error("Ascend out of order")
+					}
+					prev = item
+					return true
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	n := 0
+	tree.Ascend(func(int) bool { n++; return true })
+	assert.Equal(t, tree.Len(), n)
+}
+
+func TestSyncTreeCompoundOperations(t *testing.T) {
+	type entry struct{ key, val int }
+	tree := NewSyncTree(func(a, b entry) int { return a.key - b.key })
+
+	actual, inserted := tree.GetOrInsert(entry{1, 10})
+	assert.True(t, inserted)
+	assert.Equal(t, entry{1, 10}, actual)
+	actual, inserted = tree.GetOrInsert(entry{1, 20})
+	assert.False(t, inserted)
+	assert.Equal(t, entry{1, 10}, actual)
+
+	same := func(a, b entry) bool { return a == b }
+	assert.False(t, tree.CompareAndSwap(entry{1, 11}, entry{1, 30}, same))
+	// new would move the element out of its place in the order.
+	assert.False(t, tree.CompareAndSwap(entry{1, 10}, entry{2, 30}, same))
+	assert.True(t, tree.CompareAndSwap(entry{1, 10}, entry{1, 30}, same))
+	got, ok := tree.Get(entry{key: 1})
+	assert.True(t, ok)
+	assert.Equal(t, 30, got.val)
+
+	tree.Update(entry{key: 1}, func(old entry, exists bool) (entry, bool) {
+		assert.True(t, exists)
+		return entry{1, old.val + 1}, true
+	})
+	got, _ = tree.Get(entry{key: 1})
+	assert.Equal(t, 31, got.val)
+	tree.Update(entry{key: 2}, func(old entry, exists bool) (entry, bool) {
+		assert.False(t, exists)
+		return entry{2, 2}, true
+	})
+	tree.Update(entry{key: 1}, func(entry, bool) (entry, bool) { return entry{}, false })
+	_, ok = tree.Get(entry{key: 1})
+	assert.False(t, ok)
+	assert.Equal(t, 1, tree.Len())
+
+	var seen []int
+	tree.Insert(entry{5, 0})
+	tree.Insert(entry{7, 0})
+	tree.AscendRange(entry{key: 2}, entry{key: 7}, func(e entry) bool { seen = append(seen, e.key); return true })
+	assert.Equal(t, []int{2, 5}, seen)
+	seen = nil
+	tree.Descend(func(e entry) bool { seen = append(seen, e.key); return len(seen) < 2 })
+	assert.Equal(t, []int{7, 5}, seen)
+}
+
+func TestSyncMap(t *testing.T) {
+	m := NewSyncMap[string, int](cmp.Compare[string])
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				m.Update("counter", func(old int, exists bool) (int, bool) { return old + 1, true })
+			}
+		}()
+	}
+	wg.Wait()
+	v, ok := m.Get("counter")
+	assert.True(t, ok)
+	assert.Equal(t, 800, v)
+
+	actual, inserted := m.GetOrInsert("counter", 0)
+	assert.False(t, inserted)
+	assert.Equal(t, 800, actual)
+	same := func(a, b int) bool { return a == b }
+	assert.False(t, m.CompareAndSwap("counter", 1, 2, same))
+	assert.True(t, m.CompareAndSwap("counter", 800, 1, same))
+	assert.False(t, m.CompareAndSwap("missing", 0, 1, same))
+
+	// Values need not be comparable.
+	lists := NewSyncMap[string, []int](cmp.Compare[string])
+	lists.Set("a", []int{1})
+	assert.False(t, lists.CompareAndSwap("a", []int{2}, []int{3}, slices.Equal[[]int]))
+	assert.True(t, lists.CompareAndSwap("a", []int{1}, []int{1, 2}, slices.Equal[[]int]))
+	list, _ := lists.Get("a")
+	assert.Equal(t, []int{1, 2}, list)
+
+	m.Set("a", 0)
+	m.Set("z", 26)
+	s := m.Snapshot()
+	m.DeleteWithKey("a")
+
+	var keys []string
+	m.Ascend(func(k string, v int) bool { keys = append(keys, k); return true })
+	assert.Equal(t, []string{"counter", "z"}, keys)
+	keys = nil
+	m.AscendRange("b", "y", func(k string, v int) bool { keys = append(keys, k); return true })
+	assert.Equal(t, []string{"counter"}, keys)
+	keys = nil
+	m.Descend(func(k string, v int) bool { keys = append(keys, k); return true })
+	assert.Equal(t, []string{"z", "counter"}, keys)
+
+	_, ok = s.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, m.Len())
+}
@@ -0,0 +1,363 @@
+package rbtree
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// DurableMap is a Map whose changes are recorded in a write-ahead log
+// in a directory, so that OpenMap can recover it after a crash. The
+// directory holds two files:
+//
+//	snapshot  the Map as of the last Checkpoint, in the format of
+//	          Map.WriteTo
+//	wal       the changes made since, in order
+//
+// The log starts with the 8 bytes "RBTWAL1\n", followed by records:
+//
+//	checksum  4 bytes, little-endian CRC-32C of length and body
+//	length    4 bytes, little-endian length of body
+//	body      op byte, then for walSet a uvarint key length, the key
+//	          and the value, or for walDelete the key
+//
+// Each change is written to the log before it is applied. The write
+// reaches the operating system before Set or DeleteWithKey returns, so
+// it survives a crash of the process; call Sync to make it survive a
+// crash of the machine too. On recovery, the log is replayed up to the
+// first incomplete or corrupt record. If no valid record follows it, it
+// was left behind by a write torn by a crash, and it is discarded along
+// with the rest of the log. Otherwise the log was damaged in place, and
+// OpenMap fails with an error wrapping ErrCorrupt without changing it.
+//
+// A DurableMap is not safe for concurrent use.
+type DurableMap[K, V any] struct {
+	m          Map[K, V]
+	dir        string
+	log        *os.File
+	logSize    int64
+	keyCodec   Codec[K]
+	valueCodec Codec[V]
+	buf        []byte
+	err        error
+}
+
+const (
+	walMagic      = "RBTWAL1\n"
+	walFile       = "wal"
+	snapshotFile  = "snapshot"
+	walRecordHead = 8
+
+	walSet    = 1
+	walDelete = 2
+)
+
+var walCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrCorrupt is returned by OpenMap when a record in the middle of the
+// write-ahead log fails its checksum.
+var ErrCorrupt = errors.New("rbtree: corrupt write-ahead log")
+
+// Open the DurableMap stored in dir, creating dir and an empty map if
+// needed. The map is the latest snapshot with the log replayed on top
+// of it. Keys and values are encoded with keyCodec and valueCodec, and
+// keys ordered by compare, which must be the same every time dir is
+// opened.
+func OpenMap[K, V any](dir string, keyCodec Codec[K], valueCodec Codec[V], compare func(a, b K) int) (*DurableMap[K, V], error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	d := &DurableMap[K, V]{dir: dir, keyCodec: keyCodec, valueCodec: valueCodec}
+	if err := d.loadSnapshot(compare); err != nil {
+		return nil, err
+	}
+	if err := d.replayLog(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *DurableMap[K, V]) loadSnapshot(compare func(a, b K) int) error {
+	f, err := os.Open(filepath.Join(d.dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		d.m = NewMap[K, V](compare)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	d.m, err = ReadMap(f, d.keyCodec, d.valueCodec, compare)
+	if err != nil {
+		return fmt.Errorf("rbtree: reading snapshot in %s: %w", d.dir, err)
+	}
+	return nil
+}
+
+// Apply the valid records of the log to d.m, and cut off the rest.
+func (d *DurableMap[K, V]) replayLog() error {
+	f, err := os.OpenFile(filepath.Join(d.dir, walFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	d.log = f
+	r := bufio.NewReader(f)
+	magic := make([]byte, len(walMagic))
+	if n, err := io.ReadFull(r, magic); err != nil {
+		if n > 0 && string(magic[:n]) != walMagic[:n] {
+			f.Close()
+			return fmt.Errorf("rbtree: bad magic in %s: %w", f.Name(), ErrBadFormat)
+		}
+		// A new log, or one whose magic was torn.
+		if err := d.resetLog(); err != nil {
+			f.Close()
+			return err
+		}
+		return nil
+	}
+	if string(magic) != walMagic {
+		f.Close()
+		return fmt.Errorf("rbtree: bad magic in %s: %w", f.Name(), ErrBadFormat)
+	}
+	d.logSize = int64(len(walMagic))
+	pairs := pairCodec[K, V]{d.keyCodec, d.valueCodec}
+	head := make([]byte, walRecordHead)
+	for {
+		if _, err := io.ReadFull(r, head); err != nil {
+			break
+		}
+		length := binary.LittleEndian.Uint32(head[4:])
+		if length == 0 || int64(length) > walMaxRecord {
+			break
+		}
+		if cap(d.buf) < int(length) {
+			d.buf = make([]byte, length)
+		}
+		body := d.buf[:length]
+		if _, err := io.ReadFull(r, body); err != nil {
+			break
+		}
+		crc := crc32.Update(crc32.Checksum(head[4:], walCRCTable), walCRCTable, body)
+		if crc != binary.LittleEndian.Uint32(head) {
+			break
+		}
+		// A record that passes its checksum but does not decode was
+		// written by a different codec; that is not a torn write.
+		switch body[0] {
+		case walSet:
+			p, err := pairs.Decode(body[1:])
+			if err != nil {
+				f.Close()
+				return fmt.Errorf("rbtree: decoding %s at offset %d: %w", f.Name(), d.logSize, err)
+			}
+			d.m.Set(p.key, p.value)
+		case walDelete:
+			key, err := d.keyCodec.Decode(body[1:])
+			if err != nil {
+				f.Close()
+				return fmt.Errorf("rbtree: decoding %s at offset %d: %w", f.Name(), d.logSize, err)
+			}
+			d.m.DeleteWithKey(key)
+		default:
+			f.Close()
+			return fmt.Errorf("rbtree: unknown op %d in %s at offset %d: %w", body[0], f.Name(), d.logSize, ErrBadFormat)
+		}
+		d.logSize += walRecordHead + int64(length)
+	}
+	if err := d.checkTail(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Truncate(d.logSize); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(d.logSize, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	return nil
+}
+
+// Fail with ErrCorrupt if a valid record follows the bad one at
+// d.logSize. A crash only tears the end of the log, so the bad record
+// was then damaged in place, and truncating the log would lose the
+// records after it.
+func (d *DurableMap[K, V]) checkTail(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() <= d.logSize+walRecordHead {
+		return nil
+	}
+	tail := make([]byte, info.Size()-d.logSize)
+	if _, err := f.ReadAt(tail, d.logSize); err != nil {
+		return err
+	}
+	for i := 1; i+walRecordHead < len(tail); i++ {
+		length := binary.LittleEndian.Uint32(tail[i+4:])
+		if length == 0 || int64(length) > int64(len(tail)-i-walRecordHead) {
+			continue
+		}
+		body := tail[i+walRecordHead : i+walRecordHead+int(length)]
+		crc := crc32.Update(crc32.Checksum(tail[i+4:i+walRecordHead], walCRCTable), walCRCTable, body)
+		if crc == binary.LittleEndian.Uint32(tail[i:]) {
+			return fmt.Errorf("rbtree: bad record in %s at offset %d, followed by valid ones: %w", f.Name(), d.logSize, ErrCorrupt)
+		}
+	}
+	return nil
+}
+
+// Records are far smaller than this; a larger length is corrupt.
+const walMaxRecord = 1 << 30
+
+// Empty the log.
+func (d *DurableMap[K, V]) resetLog() error {
+	if err := d.log.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := d.log.WriteAt([]byte(walMagic), 0); err != nil {
+		return err
+	}
+	d.logSize = int64(len(walMagic))
+	_, err := d.log.Seek(d.logSize, io.SeekStart)
+	return err
+}
+
+// Append the record in d.buf[walRecordHead:] to the log.
+func (d *DurableMap[K, V]) appendRecord() error {
+	if d.err != nil {
+		return d.err
+	}
+	body := d.buf[walRecordHead:]
+	binary.LittleEndian.PutUint32(d.buf[4:], uint32(len(body)))
+	binary.LittleEndian.PutUint32(d.buf, crc32.Checksum(d.buf[4:], walCRCTable))
+	if _, err := d.log.Write(d.buf); err != nil {
+		// Do not leave a partial record in front of the next one.
+		if terr := d.log.Truncate(d.logSize); terr != nil {
+			d.err = fmt.Errorf("rbtree: log %s damaged: %w", d.log.Name(), terr)
+		} else if _, serr := d.log.Seek(d.logSize, io.SeekStart); serr != nil {
+			d.err = fmt.Errorf("rbtree: log %s damaged: %w", d.log.Name(), serr)
+		}
+		return err
+	}
+	d.logSize += int64(len(d.buf))
+	return nil
+}
+
+// Return the number of entries in the map.
+func (d *DurableMap[K, V]) Len() int {
+	return d.m.Len()
+}
+
+// Get the value of key, see Map.Get.
+func (d *DurableMap[K, V]) Get(key K) (value V, ok bool) {
+	return d.m.Get(key)
+}
+
+// Return the underlying Map, for reading only. Changes made through it
+// are not logged.
+func (d *DurableMap[K, V]) Map() Map[K, V] {
+	return d.m
+}
+
+// Log and set the value of key. Return true if the key already
+// existed. If the change cannot be logged, the map is left unchanged
+// and the error returned.
+func (d *DurableMap[K, V]) Set(key K, value V) (bool, error) {
+	d.buf = append(d.buf[:0], make([]byte, walRecordHead)...)
+	d.buf = append(d.buf, walSet)
+	d.buf = pairCodec[K, V]{d.keyCodec, d.valueCodec}.Append(d.buf, Pair[K, V]{key, value})
+	if err := d.appendRecord(); err != nil {
+		return false, err
+	}
+	return d.m.Set(key, value), nil
+}
+
+// Log the removal of key and remove it. Return true iff it was present.
+// Nothing is logged for a missing key.
+func (d *DurableMap[K, V]) DeleteWithKey(key K) (bool, error) {
+	if _, ok := d.m.Get(key); !ok {
+		return false, nil
+	}
+	d.buf = append(d.buf[:0], make([]byte, walRecordHead)...)
+	d.buf = append(d.buf, walDelete)
+	d.buf = d.keyCodec.Append(d.buf, key)
+	if err := d.appendRecord(); err != nil {
+		return false, err
+	}
+	return d.m.DeleteWithKey(key), nil
+}
+
+// Flush the log to stable storage.
+func (d *DurableMap[K, V]) Sync() error {
+	if d.err != nil {
+		return d.err
+	}
+	return d.log.Sync()
+}
+
+// Write a snapshot of the map and empty the log. A crash at any point
+// leaves either the old or the new snapshot, and a log whose replay
+// onto it gives the current map.
+func (d *DurableMap[K, V]) Checkpoint() error {
+	if d.err != nil {
+		return d.err
+	}
+	tmp, err := os.CreateTemp(d.dir, snapshotFile+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := d.m.WriteTo(tmp, d.keyCodec, d.valueCodec); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(d.dir, snapshotFile)); err != nil {
+		return err
+	}
+	if err := syncDir(d.dir); err != nil {
+		return err
+	}
+	// Replaying the old log onto the new snapshot is harmless, since
+	// each record sets or deletes a key outright.
+	if err := d.resetLog(); err != nil {
+		d.err = fmt.Errorf("rbtree: log %s damaged: %w", d.log.Name(), err)
+		return d.err
+	}
+	return d.log.Sync()
+}
+
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// Sync and close the log. The map must not be changed afterwards.
+func (d *DurableMap[K, V]) Close() error {
+	err := d.Sync()
+	if cerr := d.log.Close(); err == nil {
+		err = cerr
+	}
+	if d.err == nil {
+		d.err = os.ErrClosed
+	}
+	return err
+}
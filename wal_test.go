@@ -0,0 +1,146 @@
+package rbtree
+
+import (
+	"cmp"
+	"errors"
+	"maps"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func openTestMap(t *testing.T, dir string) *DurableMap[string, int] {
+	d, err := OpenMap(dir, StringCodec(), IntCodec[int](), cmp.Compare[string])
+	assert.NoError(t, err)
+	return d
+}
+
+func TestDurableMapRecovers(t *testing.T) {
+	dir := t.TempDir()
+	r := rand.New(rand.NewSource(0))
+	want := map[string]int{}
+	for round := 0; round < 4; round++ {
+		d := openTestMap(t, dir)
+		validateTree2(d.Map().Tree())
+		assert.Equal(t, want, maps.Collect(d.Map().All()))
+		for i := 0; i < 300; i++ {
+			key := string(rune('a' + r.Intn(26)))
+			if r.Intn(3) == 0 {
+				_, existed := want[key]
+				deleted, err := d.DeleteWithKey(key)
+				assert.NoError(t, err)
+				assert.Equal(t, existed, deleted)
+				delete(want, key)
+			} else {
+				_, err := d.Set(key, i)
+				assert.NoError(t, err)
+				want[key] = i
+			}
+		}
+		if round%2 == 1 {
+			assert.NoError(t, d.Checkpoint())
+		}
+		// Drop the map without closing it, as a crash would.
+		d.log.Close()
+	}
+	d := openTestMap(t, dir)
+	assert.Equal(t, want, maps.Collect(d.Map().All()))
+	assert.NoError(t, d.Close())
+}
+
+func TestDurableMapDiscardsTornRecords(t *testing.T) {
+	dir := t.TempDir()
+	d := openTestMap(t, dir)
+	d.Set("a", 1)
+	d.Set("b", 2)
+	good, err := os.Stat(filepath.Join(dir, walFile))
+	assert.NoError(t, err)
+	d.Set("c", 3)
+	assert.NoError(t, d.Close())
+	log, err := os.ReadFile(filepath.Join(dir, walFile))
+	assert.NoError(t, err)
+
+	for cut := good.Size(); cut < int64(len(log)); cut++ {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, walFile), log[:cut], 0o644))
+		d := openTestMap(t, dir)
+		assert.Equal(t, map[string]int{"a": 1, "b": 2}, maps.Collect(d.Map().All()))
+		assert.Equal(t, good.Size(), d.logSize)
+
+		// New records go after the last good one.
+		d.Set("d", 4)
+		assert.NoError(t, d.Close())
+		d = openTestMap(t, dir)
+		assert.Equal(t, map[string]int{"a": 1, "b": 2, "d": 4}, maps.Collect(d.Map().All()))
+		assert.NoError(t, d.Close())
+	}
+
+	// A flipped bit in the last record fails its checksum.
+	corrupt := append([]byte{}, log...)
+	corrupt[len(corrupt)-1] ^= 1
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, walFile), corrupt, 0o644))
+	d = openTestMap(t, dir)
+	assert.Equal(t, 2, d.Len())
+	_, ok := d.Get("c")
+	assert.False(t, ok)
+	assert.NoError(t, d.Close())
+}
+
+func TestDurableMapRejectsCorruptRecordInTheMiddle(t *testing.T) {
+	dir := t.TempDir()
+	d := openTestMap(t, dir)
+	d.Set("a", 1)
+	good, err := os.Stat(filepath.Join(dir, walFile))
+	assert.NoError(t, err)
+	d.Set("b", 2)
+	d.Set("c", 3)
+	assert.NoError(t, d.Close())
+	log, err := os.ReadFile(filepath.Join(dir, walFile))
+	assert.NoError(t, err)
+
+	// Flip a bit in the body of the record of "b".
+	log[good.Size()+walRecordHead+1] ^= 1
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, walFile), log, 0o644))
+	_, err = OpenMap(dir, StringCodec(), IntCodec[int](), cmp.Compare[string])
+	assert.True(t, errors.Is(err, ErrCorrupt), "%v", err)
+	after, err := os.ReadFile(filepath.Join(dir, walFile))
+	assert.NoError(t, err)
+	assert.Equal(t, log, after)
+}
+
+func TestDurableMapCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	d := openTestMap(t, dir)
+	for i := 0; i < 100; i++ {
+		d.Set("key", i)
+	}
+	d.Set("other", -1)
+	before, err := os.ReadFile(filepath.Join(dir, walFile))
+	assert.NoError(t, err)
+	assert.NoError(t, d.Checkpoint())
+	after, err := os.Stat(filepath.Join(dir, walFile))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(walMagic)), after.Size())
+	d.DeleteWithKey("other")
+	assert.NoError(t, d.Close())
+
+	d = openTestMap(t, dir)
+	assert.Equal(t, map[string]int{"key": 99}, maps.Collect(d.Map().All()))
+	assert.NoError(t, d.Close())
+
+	// A crash between writing the snapshot and emptying the log leaves
+	// the old log, whose replay gives the same map.
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, walFile), before, 0o644))
+	d = openTestMap(t, dir)
+	assert.Equal(t, map[string]int{"key": 99, "other": -1}, maps.Collect(d.Map().All()))
+	assert.NoError(t, d.Close())
+}
+
+func TestOpenMapRejectsForeignLog(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, walFile), []byte("hello, world"), 0o644))
+	_, err := OpenMap(dir, StringCodec(), IntCodec[int](), cmp.Compare[string])
+	assert.True(t, errors.Is(err, ErrBadFormat))
+}